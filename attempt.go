@@ -0,0 +1,118 @@
+package httpify
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptrace"
+	"strings"
+	"time"
+)
+
+// Attempt records a single iteration of the retry loop in Client.Do.
+type Attempt struct {
+	// Start is when the request was handed to the HTTP client.
+	Start time.Time
+	// Duration is how long it took to get the response headers or an error.
+	Duration time.Duration
+	// StatusCode is the response status code, zero when no response arrived.
+	StatusCode int
+	// Err is the transport error returned by the HTTP client, if any.
+	Err error
+	// ErrorKind is the classification of Err.
+	ErrorKind ErrorKind
+	// Drained is the number of response body bytes discarded before retrying.
+	Drained int64
+	// Wait is the backoff chosen before the next attempt, zero for the last one.
+	Wait time.Duration
+	// RemoteAddr is the address of the peer that served the attempt.
+	RemoteAddr string
+	// RequestWritten reports whether the request may have reached the server.
+	// It is set as soon as a connection is obtained, so it is false only when
+	// dialing or the TLS handshake failed.
+	RequestWritten bool
+	// PinnedKey is the pin of TLSOptions.Pins that matched the certificate
+	// chain of the response, if any.
+	PinnedKey string
+	// TLS describes the TLS connection and certificates of the response, nil
+	// for plain HTTP and failed attempts.
+	TLS *TLSInfo
+}
+
+// String returns a one line description of the attempt.
+func (a Attempt) String() string {
+	var b strings.Builder
+	switch {
+	case a.Err != nil:
+		b.WriteString(a.Err.Error())
+	case a.StatusCode != 0:
+		fmt.Fprintf(&b, "status %d", a.StatusCode)
+	default:
+		b.WriteString("no response")
+	}
+	fmt.Fprintf(&b, " in %s", a.Duration.Round(time.Millisecond))
+	if a.RemoteAddr != "" {
+		fmt.Fprintf(&b, " from %s", a.RemoteAddr)
+	}
+	if a.Wait > 0 {
+		fmt.Fprintf(&b, ", waited %s", a.Wait)
+	}
+	return b.String()
+}
+
+// summarizeAttempts describes every attempt of a call, for use in errors.
+func summarizeAttempts(attempts []Attempt) string {
+	parts := make([]string, len(attempts))
+	for i, a := range attempts {
+		parts[i] = fmt.Sprintf("#%d %s", i+1, a)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// withAttemptTrace returns a context that records the connection serving the
+// attempt. Hooks already present in ctx keep working.
+func withAttemptTrace(ctx context.Context, attempt *Attempt) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			attempt.RequestWritten = true
+			if info.Conn != nil {
+				attempt.RemoteAddr = info.Conn.RemoteAddr().String()
+			}
+		},
+	})
+}
+
+type attemptContextKey struct{}
+
+type attemptContext struct {
+	req       *Request
+	attempt   *Attempt
+	callStart time.Time
+	clock     Clock
+}
+
+// withAttempt returns a context carrying the request, its current attempt and
+// the start of the Do call it belongs to according to clock.
+func withAttempt(ctx context.Context, req *Request, attempt *Attempt, callStart time.Time, clock Clock) context.Context {
+	return context.WithValue(ctx, attemptContextKey{}, attemptContext{req, attempt, callStart, clock})
+}
+
+// elapsedFromContext returns how long ago the Do call checked by a policy
+// started.
+func elapsedFromContext(ctx context.Context) (time.Duration, bool) {
+	ac, ok := ctx.Value(attemptContextKey{}).(attemptContext)
+	if !ok {
+		return 0, false
+	}
+	return ac.clock.Now().Sub(ac.callStart), true
+}
+
+// AttemptFromContext returns the request and the record of its current attempt
+// from the context Client.Do passes to the CheckRetry policy, or from the
+// context of the request given to an AttemptMiddleware.
+func AttemptFromContext(ctx context.Context) (*Request, *Attempt, bool) {
+	ac, ok := ctx.Value(attemptContextKey{}).(attemptContext)
+	if !ok {
+		return nil, nil, false
+	}
+	return ac.req, ac.attempt, true
+}
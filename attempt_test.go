@@ -0,0 +1,47 @@
+package httpify
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttemptString(t *testing.T) {
+	tests := []struct {
+		name     string
+		attempt  Attempt
+		expected string
+	}{
+		{
+			name:     "Status",
+			attempt:  Attempt{StatusCode: 503, Duration: 12 * time.Millisecond, RemoteAddr: "10.0.0.1:443", Wait: time.Second},
+			expected: "status 503 in 12ms from 10.0.0.1:443, waited 1s",
+		},
+		{
+			name:     "Error",
+			attempt:  Attempt{Err: errors.New("connection refused"), Duration: time.Millisecond},
+			expected: "connection refused in 1ms",
+		},
+		{
+			name:     "Nothing",
+			attempt:  Attempt{},
+			expected: "no response in 0s",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.attempt.String())
+		})
+	}
+}
+
+func TestSummarizeAttempts(t *testing.T) {
+	summary := summarizeAttempts([]Attempt{
+		{StatusCode: 502, Wait: time.Second},
+		{StatusCode: 502},
+	})
+	assert.Equal(t, "#1 status 502 in 0s, waited 1s; #2 status 502 in 0s", summary)
+}
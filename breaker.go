@@ -0,0 +1,246 @@
+package httpify
+
+import (
+	"sync"
+	"time"
+)
+
+// BreakerState is the state of the circuit of a single host.
+type BreakerState int
+
+const (
+	// BreakerClosed lets every request through.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen rejects every request until the cooldown has passed.
+	BreakerOpen
+	// BreakerHalfOpen lets a limited number of probes through to decide
+	// whether the host recovered.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// BreakerOptions configures a CircuitBreaker.
+type BreakerOptions struct {
+	// FailureRatio is the share of failed attempts within a window that
+	// opens the circuit.
+	FailureRatio float64
+	// MinRequests is the number of attempts a window needs before
+	// FailureRatio is considered.
+	MinRequests int
+	// Window is the interval over which attempts are counted.
+	Window time.Duration
+	// Cooldown is how long the circuit stays open before probes are let through.
+	Cooldown time.Duration
+	// HalfOpenMax is the number of concurrent probes allowed while half-open.
+	HalfOpenMax int
+	// OnStateChange is called whenever the circuit of a host changes state.
+	OnStateChange func(host string, from, to BreakerState)
+	// Clock tells the time, SystemClock when nil.
+	Clock Clock
+}
+
+// DefaultBreakerOptions opens the circuit of a host once half of at least ten
+// attempts within a minute failed, and probes it again after 30 seconds.
+var DefaultBreakerOptions = BreakerOptions{
+	FailureRatio: 0.5,
+	MinRequests:  10,
+	Window:       time.Minute,
+	Cooldown:     30 * time.Second,
+	HalfOpenMax:  1,
+}
+
+// CircuitBreaker tracks the health of every host a Client talks to and
+// short-circuits requests to hosts that keep failing. An attempt counts as a
+// failure when it got no response or the client's CheckRetry policy wants to
+// retry it. It is safe
+// for concurrent use and may be shared between clients. The circuits of
+// hosts that are no longer contacted are dropped, see sweep.
+type CircuitBreaker struct {
+	options BreakerOptions
+
+	mu    sync.Mutex
+	hosts map[string]*hostCircuit
+	// swept is when hosts was last cleared of idle circuits.
+	swept time.Time
+}
+
+type hostCircuit struct {
+	state       BreakerState
+	windowStart time.Time
+	requests    int
+	failures    int
+	openedAt    time.Time
+	probes      int
+}
+
+type breakerOutcome int
+
+const (
+	breakerSuccess breakerOutcome = iota
+	breakerFailure
+	// breakerIgnored is used for attempts that say nothing about the host,
+	// e.g. because the caller gave up on them.
+	breakerIgnored
+)
+
+// stateChange is a transition to report once the lock is released.
+type stateChange struct {
+	host     string
+	from, to BreakerState
+}
+
+// NewCircuitBreaker returns a CircuitBreaker with the given options.
+func NewCircuitBreaker(options BreakerOptions) *CircuitBreaker {
+	if options.HalfOpenMax <= 0 {
+		options.HalfOpenMax = 1
+	}
+	options.Clock = clockOrSystem(options.Clock)
+	return &CircuitBreaker{
+		options: options,
+		hosts:   make(map[string]*hostCircuit),
+	}
+}
+
+// State returns the current state of the circuit of host.
+func (b *CircuitBreaker) State(host string) BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if hc, ok := b.hosts[host]; ok {
+		return hc.state
+	}
+	return BreakerClosed
+}
+
+// allow reports whether an attempt to host may be made. Every allowed attempt
+// must be followed by a call to record.
+func (b *CircuitBreaker) allow(host string) bool {
+	b.mu.Lock()
+	now := b.options.Clock.Now()
+	hc := b.circuit(host, now)
+
+	var change *stateChange
+	allowed := true
+	switch hc.state {
+	case BreakerClosed:
+		if now.Sub(hc.windowStart) >= b.options.Window {
+			hc.resetWindow(now)
+		}
+	case BreakerOpen:
+		if now.Sub(hc.openedAt) < b.options.Cooldown {
+			allowed = false
+			break
+		}
+		change = b.transition(host, hc, BreakerHalfOpen, now)
+		hc.probes++
+	case BreakerHalfOpen:
+		if hc.probes >= b.options.HalfOpenMax {
+			allowed = false
+			break
+		}
+		hc.probes++
+	}
+	b.mu.Unlock()
+
+	b.notify(change)
+	return allowed
+}
+
+// record reports the outcome of an attempt previously allowed.
+func (b *CircuitBreaker) record(host string, outcome breakerOutcome) {
+	b.mu.Lock()
+	now := b.options.Clock.Now()
+	hc := b.circuit(host, now)
+
+	var change *stateChange
+	switch hc.state {
+	case BreakerClosed:
+		if outcome == breakerIgnored {
+			break
+		}
+		hc.requests++
+		if outcome == breakerFailure {
+			hc.failures++
+		}
+		if hc.requests >= b.options.MinRequests &&
+			float64(hc.failures)/float64(hc.requests) >= b.options.FailureRatio {
+			change = b.transition(host, hc, BreakerOpen, now)
+		}
+	case BreakerHalfOpen:
+		hc.probes--
+		switch outcome {
+		case breakerSuccess:
+			change = b.transition(host, hc, BreakerClosed, now)
+		case breakerFailure:
+			change = b.transition(host, hc, BreakerOpen, now)
+		}
+	}
+	b.mu.Unlock()
+
+	b.notify(change)
+}
+
+func (b *CircuitBreaker) circuit(host string, now time.Time) *hostCircuit {
+	hc, ok := b.hosts[host]
+	if !ok {
+		b.sweep(now)
+		hc = &hostCircuit{windowStart: now}
+		b.hosts[host] = hc
+	}
+	return hc
+}
+
+// sweep drops, at most once per window, the circuits of idle hosts so that
+// hosts does not grow with every host ever contacted. A closed circuit whose
+// window expired is no different from a new one, the next attempt starts a
+// new window anyway. An open circuit is dropped once it was not probed for
+// a window after its cooldown.
+func (b *CircuitBreaker) sweep(now time.Time) {
+	if now.Sub(b.swept) < b.options.Window {
+		return
+	}
+	b.swept = now
+	for host, hc := range b.hosts {
+		switch {
+		case hc.state == BreakerClosed && now.Sub(hc.windowStart) >= b.options.Window,
+			hc.state == BreakerOpen && now.Sub(hc.openedAt) >= b.options.Cooldown+b.options.Window:
+			delete(b.hosts, host)
+		}
+	}
+}
+
+func (b *CircuitBreaker) transition(host string, hc *hostCircuit, to BreakerState, now time.Time) *stateChange {
+	change := &stateChange{host: host, from: hc.state, to: to}
+	hc.state = to
+	switch to {
+	case BreakerClosed:
+		hc.resetWindow(now)
+	case BreakerOpen:
+		hc.openedAt = now
+	case BreakerHalfOpen:
+		hc.probes = 0
+	}
+	return change
+}
+
+func (b *CircuitBreaker) notify(change *stateChange) {
+	if change != nil && b.options.OnStateChange != nil {
+		b.options.OnStateChange(change.host, change.from, change.to)
+	}
+}
+
+func (hc *hostCircuit) resetWindow(now time.Time) {
+	hc.windowStart = now
+	hc.requests = 0
+	hc.failures = 0
+}
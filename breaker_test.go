@@ -0,0 +1,159 @@
+package httpify
+
+import (
+	"errors"
+	"io"
+	"log"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	var changes []string
+	breaker := NewCircuitBreaker(BreakerOptions{
+		FailureRatio: 0.5,
+		MinRequests:  4,
+		Window:       time.Minute,
+		Cooldown:     50 * time.Millisecond,
+		OnStateChange: func(host string, from, to BreakerState) {
+			changes = append(changes, host+" "+from.String()+"->"+to.String())
+		},
+	})
+
+	host := "example.com"
+	for _, outcome := range []breakerOutcome{breakerSuccess, breakerFailure, breakerIgnored, breakerSuccess} {
+		assert.True(t, breaker.allow(host))
+		breaker.record(host, outcome)
+	}
+	assert.Equal(t, BreakerClosed, breaker.State(host))
+
+	assert.True(t, breaker.allow(host))
+	breaker.record(host, breakerFailure)
+	assert.Equal(t, BreakerOpen, breaker.State(host))
+	assert.False(t, breaker.allow(host))
+	assert.Equal(t, BreakerClosed, breaker.State("other.com"))
+
+	time.Sleep(60 * time.Millisecond)
+	assert.True(t, breaker.allow(host))
+	assert.Equal(t, BreakerHalfOpen, breaker.State(host))
+	assert.False(t, breaker.allow(host), "only one probe is allowed while half-open")
+	breaker.record(host, breakerFailure)
+	assert.Equal(t, BreakerOpen, breaker.State(host))
+
+	time.Sleep(60 * time.Millisecond)
+	assert.True(t, breaker.allow(host))
+	breaker.record(host, breakerSuccess)
+	assert.Equal(t, BreakerClosed, breaker.State(host))
+
+	assert.Equal(t, []string{
+		"example.com closed->open",
+		"example.com open->half-open",
+		"example.com half-open->open",
+		"example.com open->half-open",
+		"example.com half-open->closed",
+	}, changes)
+}
+
+func TestCircuitBreakerDropsIdleHosts(t *testing.T) {
+	clock := &steppedClock{now: time.Now()}
+	breaker := NewCircuitBreaker(BreakerOptions{
+		FailureRatio: 0.5,
+		MinRequests:  1,
+		Window:       time.Minute,
+		Cooldown:     time.Minute,
+		Clock:        clock,
+	})
+
+	for _, host := range []string{"a.example", "b.example"} {
+		assert.True(t, breaker.allow(host))
+		breaker.record(host, breakerSuccess)
+	}
+	assert.True(t, breaker.allow("down.example"))
+	breaker.record("down.example", breakerFailure)
+	assert.Len(t, breaker.hosts, 3)
+
+	// Circuits are only dropped once their window expired.
+	clock.Advance(30 * time.Second)
+	assert.True(t, breaker.allow("c.example"))
+	assert.Len(t, breaker.hosts, 4)
+
+	clock.Advance(time.Minute)
+	assert.True(t, breaker.allow("d.example"))
+	assert.ElementsMatch(t, []string{"d.example", "down.example"}, slices.Collect(maps.Keys(breaker.hosts)))
+	assert.Equal(t, BreakerOpen, breaker.State("down.example"))
+
+	clock.Advance(2 * time.Minute)
+	assert.True(t, breaker.allow("e.example"))
+	assert.ElementsMatch(t, []string{"e.example"}, slices.Collect(maps.Keys(breaker.hosts)))
+}
+
+func TestDoCircuitOpen(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := NewClient(Options{RetryMax: 5, Timeout: 5 * time.Second, RespReadLimit: 4096})
+	client.CircuitBreaker = NewCircuitBreaker(BreakerOptions{
+		FailureRatio: 1,
+		MinRequests:  2,
+		Window:       time.Minute,
+		Cooldown:     time.Minute,
+	})
+
+	req, _ := NewRequest(http.MethodGet, server.URL, nil)
+	_, err := client.Do(req)
+
+	var open *CircuitOpenError
+	if assert.ErrorAs(t, err, &open) {
+		u, _ := url.Parse(server.URL)
+		assert.Equal(t, u.Host, open.Host)
+		assert.Equal(t, 2, open.Attempts)
+		assert.Equal(t, http.StatusServiceUnavailable, open.StatusCode)
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	// Further calls are rejected without reaching the server.
+	req, _ = NewRequest(http.MethodGet, server.URL, nil)
+	_, err = client.Do(req)
+	assert.True(t, errors.As(err, &open))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestDoCircuitOpensOnTerminalErrors(t *testing.T) {
+	var calls int32
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+	}))
+	defer server.Close()
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+
+	client := NewClient(Options{RetryMax: 3, Timeout: 5 * time.Second})
+	client.CircuitBreaker = NewCircuitBreaker(BreakerOptions{
+		FailureRatio: 1,
+		MinRequests:  2,
+		Window:       time.Minute,
+		Cooldown:     time.Minute,
+	})
+
+	// Certificate errors are not retried but still count against the host.
+	for i := 0; i < 2; i++ {
+		req, _ := NewRequest(http.MethodGet, server.URL, nil)
+		_, err := client.Do(req)
+		assert.Equal(t, KindTLSUnknownAuthority, Classify(err))
+		assert.Len(t, req.Metrics.Attempts, 1)
+	}
+	u, _ := url.Parse(server.URL)
+	assert.Equal(t, BreakerOpen, client.CircuitBreaker.State(u.Host))
+	assert.Zero(t, atomic.LoadInt32(&calls))
+}
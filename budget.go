@@ -0,0 +1,111 @@
+package httpify
+
+import (
+	"sync"
+	"time"
+)
+
+// RetryBudgetOptions configures a RetryBudget.
+type RetryBudgetOptions struct {
+	// Ratio is the number of retries every successful request earns, e.g.
+	// 0.2 allows one retry for every five successful requests.
+	Ratio float64
+	// MinRetriesPerSecond is the number of retries allowed every second even
+	// when the budget is empty, so that an idle client can still retry.
+	MinRetriesPerSecond int
+	// MaxBalance caps the number of retries that can be saved up.
+	MaxBalance float64
+	// Clock tells the time, SystemClock when nil.
+	Clock Clock
+}
+
+// DefaultRetryBudgetOptions allows retries for up to 20% of the successful
+// requests, with a floor of ten retries per second.
+var DefaultRetryBudgetOptions = RetryBudgetOptions{
+	Ratio:               0.2,
+	MinRetriesPerSecond: 10,
+	MaxBalance:          100,
+}
+
+// RetryBudget is a token bucket shared by every request of a Client to keep
+// retries from multiplying the load on a degraded upstream. Successful
+// requests deposit tokens and every retry withdraws one. It is safe for
+// concurrent use.
+type RetryBudget struct {
+	options RetryBudgetOptions
+
+	mu          sync.Mutex
+	balance     float64
+	second      time.Time
+	floorUsed   int
+	deposits    uint64
+	withdrawals uint64
+	rejected    uint64
+}
+
+// RetryBudgetStats is a snapshot of the usage of a RetryBudget.
+type RetryBudgetStats struct {
+	// Balance is the number of retries currently saved up.
+	Balance float64
+	// Deposits is the number of successful requests seen.
+	Deposits uint64
+	// Withdrawals is the number of retries allowed.
+	Withdrawals uint64
+	// Rejected is the number of retries refused because the budget was empty.
+	Rejected uint64
+}
+
+// NewRetryBudget returns an empty RetryBudget with the given options.
+func NewRetryBudget(options RetryBudgetOptions) *RetryBudget {
+	options.Clock = clockOrSystem(options.Clock)
+	return &RetryBudget{options: options}
+}
+
+// Stats returns the current usage of the budget.
+func (b *RetryBudget) Stats() RetryBudgetStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return RetryBudgetStats{
+		Balance:     b.balance,
+		Deposits:    b.deposits,
+		Withdrawals: b.withdrawals,
+		Rejected:    b.rejected,
+	}
+}
+
+// deposit credits the budget for a successful request.
+func (b *RetryBudget) deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.deposits++
+	b.balance += b.options.Ratio
+	if b.options.MaxBalance > 0 && b.balance > b.options.MaxBalance {
+		b.balance = b.options.MaxBalance
+	}
+}
+
+// withdraw reports whether a retry may be made and charges the budget for it.
+func (b *RetryBudget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.balance >= 1 {
+		b.balance--
+		b.withdrawals++
+		return true
+	}
+
+	now := b.options.Clock.Now()
+	if now.Sub(b.second) >= time.Second {
+		b.second = now
+		b.floorUsed = 0
+	}
+	if b.floorUsed < b.options.MinRetriesPerSecond {
+		b.floorUsed++
+		b.withdrawals++
+		return true
+	}
+
+	b.rejected++
+	return false
+}
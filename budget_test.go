@@ -0,0 +1,50 @@
+package httpify
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryBudget(t *testing.T) {
+	budget := NewRetryBudget(RetryBudgetOptions{Ratio: 0.5, MinRetriesPerSecond: 1, MaxBalance: 1})
+
+	// The floor allows a single retry per second on an empty budget.
+	assert.True(t, budget.withdraw())
+	assert.False(t, budget.withdraw())
+
+	// Deposits are capped by MaxBalance.
+	for i := 0; i < 4; i++ {
+		budget.deposit()
+	}
+	assert.True(t, budget.withdraw())
+	assert.False(t, budget.withdraw())
+
+	assert.Equal(t, RetryBudgetStats{Balance: 0, Deposits: 4, Withdrawals: 2, Rejected: 2}, budget.Stats())
+}
+
+func TestDoRetryBudgetExhausted(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := NewClient(Options{RetryMax: 5, Timeout: 5 * time.Second, RespReadLimit: 4096})
+	client.RetryBudget = NewRetryBudget(RetryBudgetOptions{MinRetriesPerSecond: 1})
+
+	req, _ := NewRequest(http.MethodGet, server.URL, nil)
+	resp, err := client.Do(req)
+	assert.Nil(t, err)
+	if assert.NotNil(t, resp) {
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		resp.Body.Close()
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	assert.Equal(t, uint64(1), client.RetryBudget.Stats().Rejected)
+}
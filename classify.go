@@ -0,0 +1,200 @@
+package httpify
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"syscall"
+)
+
+// ErrorKind is a stable classification of the error returned by an attempt.
+// New kinds are only ever appended, so the values can be stored.
+type ErrorKind int
+
+const (
+	// KindNone is the kind of a nil error.
+	KindNone ErrorKind = iota
+	// KindUnknown is used for errors that fit no other kind.
+	KindUnknown
+	// KindCanceled means the request context was cancelled.
+	KindCanceled
+	// KindTimeout means a deadline or timeout was exceeded.
+	KindTimeout
+	// KindConnection covers connection failures not described by a more
+	// specific kind.
+	KindConnection
+	// KindTLS covers TLS failures not described by a more specific kind.
+	KindTLS
+	// KindRedirect means the redirect limit was hit.
+	KindRedirect
+	// KindScheme means the URL scheme is not supported.
+	KindScheme
+	// KindDNSNotFound means the host does not exist (NXDOMAIN).
+	KindDNSNotFound
+	// KindDNSTemporary covers DNS failures that may go away, such as a
+	// timed out or unreachable resolver.
+	KindDNSTemporary
+	// KindConnRefused means the peer refused the connection.
+	KindConnRefused
+	// KindConnReset means the peer reset an established connection.
+	KindConnReset
+	// KindEOF means the connection was closed before a complete response.
+	KindEOF
+	// KindTLSHandshakeTimeout means the TLS handshake did not complete in time.
+	KindTLSHandshakeTimeout
+	// KindTLSUnknownAuthority means the certificate is signed by an unknown
+	// authority.
+	KindTLSUnknownAuthority
+	// KindTLSHostnameMismatch means the certificate is not valid for the host.
+	KindTLSHostnameMismatch
+	// KindTLSCertExpired means the certificate expired or is not valid yet.
+	KindTLSCertExpired
+	// KindTLSCertInvalid covers other certificate verification failures.
+	KindTLSCertInvalid
+	// KindProxy means connecting to the proxy failed.
+	KindProxy
+	// KindTLSPinMismatch means no certificate of the chain has a pinned
+	// public key, see TLSOptions.Pins.
+	KindTLSPinMismatch
+)
+
+var errorKindNames = map[ErrorKind]string{
+	KindNone:                "none",
+	KindUnknown:             "unknown",
+	KindCanceled:            "canceled",
+	KindTimeout:             "timeout",
+	KindConnection:          "connection",
+	KindTLS:                 "tls",
+	KindRedirect:            "redirect",
+	KindScheme:              "scheme",
+	KindDNSNotFound:         "dns_not_found",
+	KindDNSTemporary:        "dns_temporary",
+	KindConnRefused:         "conn_refused",
+	KindConnReset:           "conn_reset",
+	KindEOF:                 "eof",
+	KindTLSHandshakeTimeout: "tls_handshake_timeout",
+	KindTLSUnknownAuthority: "tls_unknown_authority",
+	KindTLSHostnameMismatch: "tls_hostname_mismatch",
+	KindTLSCertExpired:      "tls_cert_expired",
+	KindTLSCertInvalid:      "tls_cert_invalid",
+	KindProxy:               "proxy",
+	KindTLSPinMismatch:      "tls_pin_mismatch",
+}
+
+func (k ErrorKind) String() string {
+	if name, ok := errorKindNames[k]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// MarshalText encodes the kind as its name.
+func (k ErrorKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// IsTLSCert reports whether the kind is a certificate verification failure,
+// pinning included.
+func (k ErrorKind) IsTLSCert() bool {
+	switch k {
+	case KindTLSUnknownAuthority, KindTLSHostnameMismatch, KindTLSCertExpired, KindTLSCertInvalid, KindTLSPinMismatch:
+		return true
+	}
+	return false
+}
+
+// Classify unwraps err, typically a *url.Error returned by an http.Client,
+// and returns its kind.
+func Classify(err error) ErrorKind {
+	if err == nil {
+		return KindNone
+	}
+	if errors.Is(err, context.Canceled) {
+		return KindCanceled
+	}
+
+	if urlErr, ok := err.(*url.Error); ok {
+		switch {
+		case redirectsErrorRegex.MatchString(urlErr.Error()):
+			return KindRedirect
+		case schemeErrorRegex.MatchString(urlErr.Error()):
+			return KindScheme
+		}
+	}
+
+	if kind := classifyTLS(err); kind != KindNone {
+		return kind
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		if dnsErr.IsNotFound {
+			return KindDNSNotFound
+		}
+		return KindDNSTemporary
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "proxyconnect" {
+		return KindProxy
+	}
+
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return KindConnRefused
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE):
+		return KindConnReset
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return KindEOF
+	case errors.Is(err, context.DeadlineExceeded):
+		return KindTimeout
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return KindTimeout
+	}
+	if opErr != nil {
+		return KindConnection
+	}
+	return KindUnknown
+}
+
+// classifyTLS returns the kind of TLS failures and KindNone for anything else.
+func classifyTLS(err error) ErrorKind {
+	var (
+		unknownAuthority x509.UnknownAuthorityError
+		hostname         x509.HostnameError
+		invalid          x509.CertificateInvalidError
+		verification     *tls.CertificateVerificationError
+		pinMismatch      *PinMismatchError
+		recordHeader     tls.RecordHeaderError
+		alert            tls.AlertError
+	)
+	switch {
+	case errors.As(err, &pinMismatch):
+		return KindTLSPinMismatch
+	case errors.As(err, &unknownAuthority):
+		return KindTLSUnknownAuthority
+	case errors.As(err, &hostname):
+		return KindTLSHostnameMismatch
+	case errors.As(err, &invalid):
+		if invalid.Reason == x509.Expired {
+			return KindTLSCertExpired
+		}
+		return KindTLSCertInvalid
+	case errors.As(err, &verification):
+		return KindTLSCertInvalid
+	case errors.As(err, &recordHeader), errors.As(err, &alert):
+		return KindTLS
+	case strings.Contains(err.Error(), "TLS handshake timeout"):
+		// net/http does not export the type of this error.
+		return KindTLSHandshakeTimeout
+	}
+	return KindNone
+}
@@ -0,0 +1,67 @@
+package httpify
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassify(t *testing.T) {
+	wrap := func(err error) error {
+		return &url.Error{Op: "Get", URL: "https://example.com", Err: err}
+	}
+	dial := func(err error) error {
+		return wrap(&net.OpError{Op: "dial", Net: "tcp", Err: &os.SyscallError{Syscall: "connect", Err: err}})
+	}
+
+	tests := []struct {
+		name     string
+		err      error
+		expected ErrorKind
+	}{
+		{"Nil", nil, KindNone},
+		{"Unknown", errors.New("boom"), KindUnknown},
+		{"Canceled", wrap(context.Canceled), KindCanceled},
+		{"Deadline", wrap(context.DeadlineExceeded), KindTimeout},
+		{"Redirects", wrap(errors.New("stopped after 10 redirects")), KindRedirect},
+		{"Scheme", wrap(errors.New("unsupported protocol scheme \"ftp\"")), KindScheme},
+		{"NXDOMAIN", wrap(&net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "nope.invalid", IsNotFound: true}}), KindDNSNotFound},
+		{"DNS timeout", wrap(&net.OpError{Op: "dial", Err: &net.DNSError{Err: "i/o timeout", IsTimeout: true}}), KindDNSTemporary},
+		{"Refused", dial(syscall.ECONNREFUSED), KindConnRefused},
+		{"Reset", wrap(&net.OpError{Op: "read", Err: &os.SyscallError{Syscall: "read", Err: syscall.ECONNRESET}}), KindConnReset},
+		{"Other connection error", dial(syscall.EHOSTUNREACH), KindConnection},
+		{"EOF", wrap(io.EOF), KindEOF},
+		{"Proxy", wrap(&net.OpError{Op: "proxyconnect", Net: "tcp", Err: syscall.ECONNREFUSED}), KindProxy},
+		{"Handshake timeout", wrap(errors.New("net/http: TLS handshake timeout")), KindTLSHandshakeTimeout},
+		{"Unknown authority", wrap(x509.UnknownAuthorityError{}), KindTLSUnknownAuthority},
+		{"Hostname mismatch", wrap(x509.HostnameError{Certificate: &x509.Certificate{}, Host: "example.com"}), KindTLSHostnameMismatch},
+		{"Expired", wrap(x509.CertificateInvalidError{Reason: x509.Expired}), KindTLSCertExpired},
+		{"Invalid", wrap(x509.CertificateInvalidError{Reason: x509.NotAuthorizedToSign}), KindTLSCertInvalid},
+		{"Pin mismatch", wrap(&PinMismatchError{Host: "example.com"}), KindTLSPinMismatch},
+		{"Wrapped", fmt.Errorf("outer: %w", dial(syscall.ECONNREFUSED)), KindConnRefused},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Classify(tt.err))
+		})
+	}
+}
+
+func TestErrorKindString(t *testing.T) {
+	assert.Equal(t, "conn_refused", KindConnRefused.String())
+	assert.Equal(t, "unknown", ErrorKind(-1).String())
+
+	text, err := KindTLSHostnameMismatch.MarshalText()
+	assert.Nil(t, err)
+	assert.Equal(t, "tls_hostname_mismatch", string(text))
+}
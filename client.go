@@ -1,6 +1,8 @@
 package httpify
 
 import (
+	"log/slog"
+	"math/rand"
 	"net/http"
 	"time"
 )
@@ -13,17 +15,67 @@ type Client struct {
 	ErrorHandler    ErrorHandler
 	CheckRetry      CheckRetry
 	RetryStrategy   RetryStrategy
-	options         Options
+	// CircuitBreaker, when set, short-circuits requests to failing hosts.
+	CircuitBreaker *CircuitBreaker
+	// RetryBudget, when set, caps the share of requests that may be retried.
+	RetryBudget *RetryBudget
+	// Hedger configures the duplicate requests sent by DoHedged.
+	Hedger *Hedger
+	// HostRules, when set, overrides settings for matching hosts.
+	HostRules *HostRules
+	// TraceSink, when set, receives the connection trace of every attempt.
+	// Request.TraceSink takes precedence.
+	TraceSink TraceSink
+	// Logger, when set, receives structured records of every attempt, retry
+	// and outcome, see LogLevels for the events.
+	Logger *slog.Logger
+	// LogLevels sets the level of each event, DefaultLogLevels when nil.
+	LogLevels *LogLevels
+	// Middlewares wrap every call to Do, the first one outermost.
+	Middlewares []Middleware
+	// AttemptMiddlewares wrap every attempt made by Do within the retry loop,
+	// the first one outermost.
+	AttemptMiddlewares []AttemptMiddleware
+	// Stats, when set, aggregates the statistics of every call. It may be
+	// shared by several clients.
+	Stats *StatsCollector
+	// Dump, when set, writes the request and response of every attempt.
+	Dump *DumpOptions
+	// Redactor hides secrets in error messages, logs, traces and dumps,
+	// DefaultRedactor when nil. An empty Redactor only hides URL passwords.
+	Redactor *Redactor
+	// Clock tells the time and creates the backoff timers, SystemClock when nil.
+	Clock Clock
+	// Rand generates idempotency keys, crypto/rand when nil. It must be safe
+	// for concurrent use, see NewRand.
+	Rand    *rand.Rand
+	options Options
+	// reloader reloads the files of the TLS options, if any.
+	reloader *certReloader
+	// configErr is the error that made NewClient fail to apply the options.
+	configErr error
 }
 
 // Options defines retryable settings for the HTTP client.
 type Options struct {
-	RetryWaitMin  time.Duration
-	RetryWaitMax  time.Duration
-	Timeout       time.Duration
-	RetryMax      int
-	RespReadLimit int64
-	KillIdleConn  bool
+	RetryWaitMin time.Duration
+	RetryWaitMax time.Duration
+	// Timeout is the timeout of the http.Client built by NewClient.
+	Timeout time.Duration
+	// TotalTimeout bounds a whole Do call, attempts and backoff included.
+	// Zero means no bound other than the request context.
+	TotalTimeout time.Duration
+	// AttemptTimeout bounds every single attempt. Zero means no bound other
+	// than the HTTP client timeout.
+	AttemptTimeout time.Duration
+	RetryMax       int
+	RespReadLimit  int64
+	KillIdleConn   bool
+	// IdempotencyKey adds a random Idempotency-Key header, stable across
+	// attempts, to requests with a non-idempotent method that lack one.
+	IdempotencyKey bool
+	// TLS configures the transport built by NewClient.
+	TLS TLSOptions
 }
 
 // Default options for spraying multiple hosts.
@@ -46,22 +98,32 @@ var DefaultOptionsSingle = Options{
 	KillIdleConn:  false,
 }
 
-// NewClient initializes a Client with specified options.
+// NewClient initializes a Client with specified options. When options.TLS
+// cannot be loaded, every call to Do fails with the reason.
 func NewClient(options Options) *Client {
 	httpClient := DefaultHTTPClient(options.Timeout)
+	tlsConfig, reloader, err := options.TLS.config()
+	if err == nil {
+		transport := httpClient.Transport.(*http.Transport)
+		transport.TLSClientConfig = tlsConfig
+		reloader.attach(transport)
+	}
 	return &Client{
 		HTTPClient:    httpClient,
-		CheckRetry:    DefaultRetryPolicy(),
+		CheckRetry:    IdempotentRetryPolicy(StatusRetryPolicy()),
 		RetryStrategy: DefaultRetryStrategy(),
 		options:       options,
+		reloader:      reloader,
+		configErr:     err,
 	}
 }
 
-// NewWithHTTPClient initializes a Client with a custom HTTP client.
+// NewWithHTTPClient initializes a Client with a custom HTTP client. The TLS
+// options are ignored, the transport of client is used as is.
 func NewWithHTTPClient(client *http.Client, options Options) *Client {
 	return &Client{
 		HTTPClient:    client,
-		CheckRetry:    DefaultRetryPolicy(),
+		CheckRetry:    IdempotentRetryPolicy(StatusRetryPolicy()),
 		RetryStrategy: DefaultRetryStrategy(),
 		options:       options,
 	}
@@ -72,6 +134,11 @@ func DefaultHTTPClient(timeout time.Duration) *http.Client {
 	return &http.Client{Timeout: timeout, Transport: NoKeepAliveTransport()}
 }
 
+// clock returns the clock of the client.
+func (c *Client) clock() Clock {
+	return clockOrSystem(c.Clock)
+}
+
 // setKillIdleConnections configures connection keep-alive behavior based on options.
 func (c *Client) setKillIdleConnections() {
 	if c.HTTPClient != nil || !c.options.KillIdleConn {
@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"sync"
 	"time"
 )
 
@@ -13,22 +15,114 @@ func PassthroughErrorHandler(resp *http.Response, err error, _ int) (*http.Respo
 	return resp, err
 }
 
-// Do sends an HTTP request with retries and retryStrategy.
+// Do sends an HTTP request with retries and retryStrategy, through the
+// middlewares of the client.
 func (c *Client) Do(req *Request) (*http.Response, error) {
+	do := DoFunc(c.doObserved)
+	for i := len(c.Middlewares) - 1; i >= 0; i-- {
+		do = c.Middlewares[i](do)
+	}
+	return do(req)
+}
+
+// doObserved runs the retry loop and reports its outcome to the logger and
+// the stats collector of the client.
+func (c *Client) doObserved(req *Request) (*http.Response, error) {
+	if c.Logger == nil && c.Stats == nil {
+		return c.do(req)
+	}
+
+	first, start := len(req.Metrics.Attempts), c.clock().Now()
+	resp, err := c.do(req)
+	if c.Stats != nil {
+		c.Stats.recordCall(req.URL.Host, err)
+	}
+	if c.Logger == nil {
+		return resp, err
+	}
+	attrs := []slog.Attr{
+		slog.Int(LogKeyAttempts, len(req.Metrics.Attempts)-first),
+		slog.Duration(LogKeyDuration, c.clock().Now().Sub(start)),
+	}
+	if resp != nil {
+		attrs = append(attrs, slog.Int(LogKeyStatus, resp.StatusCode))
+	}
+	if err != nil {
+		c.log(req.Context(), eventGiveUp, req, append(attrs, errorAttrs(c.redactor().Error(err))...)...)
+	} else {
+		c.log(req.Context(), eventSuccess, req, attrs...)
+	}
+	return resp, err
+}
+
+func (c *Client) do(req *Request) (*http.Response, error) {
 	var resp *http.Response
 	var err error
+	// reason is why the last attempt was retried, reported once retries run
+	// out. Unlike err it includes the reason given by the policy for a
+	// response, e.g. a retryable status code.
+	var reason error
+
+	if c.configErr != nil {
+		return nil, fmt.Errorf("httpify: invalid client options: %w", c.configErr)
+	}
+
+	s := c.settingsFor(req)
+
+	// The main context bounds the whole operation, attempts and backoff
+	// included. It is released once the returned body is closed.
+	ctx, cancel := req.Context(), context.CancelFunc(func() {})
+	if s.TotalTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, s.TotalTimeout)
+	}
+	attemptCancel := context.CancelFunc(func() {})
 
-	// Create a main context that will be used as the main timeout
-	mainCtx, cancel := context.WithTimeout(context.Background(), c.options.Timeout)
-	defer cancel()
+	if err := c.setIdempotencyKey(req); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	clock := c.clock()
+
+	httpClient := c.HTTPClient
+	if s.rule != nil {
+		s.rule.setHeader(req)
+		if httpClient, err = s.rule.httpClient(c.HTTPClient); err != nil {
+			cancel()
+			return nil, err
+		}
+	}
 
+	// The dump is innermost so that it shows what middlewares send.
+	send := AttemptFunc(httpClient.Do)
+	if dumper := c.newDumper(); dumper != nil {
+		send = dumper.wrap(send)
+	}
+	for i := len(c.AttemptMiddlewares) - 1; i >= 0; i-- {
+		send = c.AttemptMiddlewares[i](send)
+	}
+
+	// Attempts made by earlier calls with the same request are not part of
+	// this call's history.
+	first := len(req.Metrics.Attempts)
+
+retry:
 	for i := 0; ; i++ {
+		if s.rule != nil && s.rule.limiter != nil {
+			if err := s.rule.limiter.wait(ctx, clock); err != nil {
+				cancel()
+				c.closeIdleConnections()
+				return nil, &BackoffCanceledError{c.errorDetails(req, req.Metrics.Attempts[first:], err)}
+			}
+		}
+
 		// Always rewind the request body when non-nil.
 		if req.body != nil {
 			body, err := req.body()
 			if err != nil {
+				cancel()
 				c.closeIdleConnections()
-				return resp, err
+				return nil, &BodyRewindError{c.errorDetails(req, req.Metrics.Attempts[first:], err)}
 			}
 			if c, ok := body.(io.ReadCloser); ok {
 				req.Body = c
@@ -37,15 +131,57 @@ func (c *Client) Do(req *Request) (*http.Response, error) {
 			}
 		}
 
+		if c.CircuitBreaker != nil && !c.CircuitBreaker.allow(req.URL.Host) {
+			cancel()
+			c.closeIdleConnections()
+			return nil, &CircuitOpenError{c.errorDetails(req, req.Metrics.Attempts[first:], err), req.URL.Host}
+		}
+
 		if c.RequestLogHook != nil {
 			c.RequestLogHook(req.Request, i)
 		}
+		c.log(ctx, eventAttemptStart, req, slog.Int(LogKeyAttempt, i+1), slog.Any(LogKeyHeaders, logHeaders{req.Header, c.redactor()}))
+
+		attemptCtx := ctx
+		if s.AttemptTimeout > 0 {
+			attemptCtx, attemptCancel = context.WithTimeout(ctx, s.AttemptTimeout)
+		}
 
 		// Attempt the request
-		resp, err = c.HTTPClient.Do(req.Request)
+		req.Metrics.Attempts = append(req.Metrics.Attempts, Attempt{Start: clock.Now()})
+		attempt := &req.Metrics.Attempts[len(req.Metrics.Attempts)-1]
+		traceCtx := withAttemptTrace(attemptCtx, attempt)
+		var tracer *connTracer
+		if sink := c.traceSink(req); sink != nil {
+			tracer = newConnTracer(sink, clock, c.redactor(), req, i+1)
+			traceCtx = tracer.withContext(traceCtx)
+		}
+		traceCtx = withAttempt(traceCtx, req, attempt, req.Metrics.Attempts[first].Start, clock)
+		resp, err = send(req.Request.WithContext(traceCtx))
+		if tracer != nil {
+			tracer.finish(resp, err)
+		}
+		attempt.Duration = clock.Now().Sub(attempt.Start)
+		attempt.Err = err
+		attempt.ErrorKind = Classify(err)
+		if resp != nil {
+			attempt.StatusCode = resp.StatusCode
+			if resp.TLS != nil {
+				attempt.TLS = NewTLSInfo(resp.TLS)
+				attempt.PinnedKey = c.pinnedKey(s, req.URL.Hostname(), resp.TLS)
+			}
+		}
+		c.logAttemptResult(ctx, req, i+1, attempt, resp)
+		if c.Stats != nil {
+			c.Stats.recordAttempt(req.URL.Host, attempt, req.ContentLength)
+			c.Stats.countBody(req.URL.Host, resp)
+		}
 
 		// Check if we should continue with retries.
-		checkOK, checkErr := c.CheckRetry(req.Context(), resp, err)
+		checkOK, checkErr := s.CheckRetry(withAttempt(ctx, req, attempt, req.Metrics.Attempts[first].Start, clock), resp, err)
+		if c.CircuitBreaker != nil {
+			c.CircuitBreaker.record(req.URL.Host, breakerOutcomeOf(ctx, err, checkOK))
+		}
 
 		if err != nil {
 			// Increment the failure counter as the request failed
@@ -61,47 +197,104 @@ func (c *Client) Do(req *Request) (*http.Response, error) {
 
 		// Now decide if we should continue.
 		if !checkOK {
+			c.closeIdleConnections()
+			// A response the policy does not retry, e.g. a 4xx, is handed back
+			// as is whatever the policy says about it.
+			if err == nil && ctx.Err() == nil {
+				if c.RetryBudget != nil {
+					c.RetryBudget.deposit()
+				}
+				return releaseOnClose(resp, attemptCancel, cancel), nil
+			}
 			if checkErr != nil {
 				err = checkErr
 			}
-			c.closeIdleConnections()
-			return resp, err
+			if err == nil {
+				err = ctx.Err()
+			}
+			// The caller gets no response along with the error, so nothing
+			// else would release it.
+			if resp != nil {
+				resp.Body.Close()
+			}
+			attemptCancel()
+			cancel()
+			return nil, &RetryAbortedError{c.errorDetails(req, req.Metrics.Attempts[first:], err)}
+		}
+
+		reason = err
+		if reason == nil {
+			reason = checkErr
 		}
 
 		// We do this before drainBody beause there's no need for the I/O if
 		// we're breaking out
-		remain := c.options.RetryMax - i
+		remain := s.RetryMax - i
 		if remain <= 0 {
 			break
 		}
 
+		// Wait for the time specified by retryStrategy then retry.
+		wait := s.RetryStrategy(s.RetryWaitMin, s.RetryWaitMax, i, resp)
+
+		// Give up right away when the time left cannot fit the wait and
+		// another attempt. Context deadlines run on the real time whatever
+		// the clock of the client.
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= wait {
+			break
+		}
+
+		// When the retry budget is used up, hand back the last response or error
+		// as is instead of retrying.
+		if c.RetryBudget != nil && !c.RetryBudget.withdraw() {
+			c.closeIdleConnections()
+			if attempt.Err != nil {
+				attemptCancel()
+				cancel()
+				return nil, &RetryBudgetExhaustedError{c.errorDetails(req, req.Metrics.Attempts[first:], attempt.Err)}
+			}
+			return releaseOnClose(resp, attemptCancel, cancel), nil
+		}
+
 		// Increment the retries counter as we are going to do one more retry
 		req.Metrics.Retries++
+		if c.Stats != nil {
+			c.Stats.recordRetry(req.URL.Host)
+		}
 
 		// We're going to retry, consume any response to reuse the connection.
-		if err == nil && resp != nil {
-			c.drainBody(req, resp)
+		if resp != nil {
+			attempt.Drained = c.drainBody(req, resp, s.RespReadLimit)
 		}
+		attemptCancel()
+		attempt.Wait = wait
+		c.logRetryScheduled(ctx, req, i+1, wait, reason)
 
-		// Wait for the time specified by retryStrategy then retry.
-		// If the context is cancelled however, return.
-		wait := c.RetryStrategy(c.options.RetryWaitMin, c.options.RetryWaitMax, i, resp)
-
-		// Exit if the main context or the request context is done
-		// Otherwise, wait for the duration and try again.
+		// Exit if the request context is cancelled or the main context runs
+		// out, otherwise wait for the duration and try again.
+		timer := clock.NewTimer(wait)
 		select {
-		case <-mainCtx.Done():
-			break
-		case <-req.Context().Done():
-			c.closeIdleConnections()
-			return nil, req.Context().Err()
-		case <-time.After(wait):
+		case <-ctx.Done():
+			timer.Stop()
+			if req.Context().Err() != nil {
+				cancel()
+				c.closeIdleConnections()
+				return nil, &BackoffCanceledError{c.errorDetails(req, req.Metrics.Attempts[first:], req.Context().Err())}
+			}
+			resp = nil
+			if err == nil {
+				err = ctx.Err()
+			}
+			break retry
+		case <-timer.C():
 		}
 	}
 
+	history := req.Metrics.Attempts[first:]
 	if c.ErrorHandler != nil {
 		c.closeIdleConnections()
-		return c.ErrorHandler(resp, err, c.options.RetryMax+1)
+		resp, err = c.ErrorHandler(resp, err, len(history))
+		return releaseOnClose(resp, attemptCancel, cancel), err
 	}
 
 	// By default, we close the response body and return an error without
@@ -109,8 +302,54 @@ func (c *Client) Do(req *Request) (*http.Response, error) {
 	if resp != nil {
 		resp.Body.Close()
 	}
+	attemptCancel()
+	cancel()
 	c.closeIdleConnections()
-	return nil, fmt.Errorf("%s %s giving up after %d attempts: %w", req.Method, req.URL, c.options.RetryMax+1, err)
+	return nil, &RetriesExhaustedError{c.errorDetails(req, history, reason), history}
+}
+
+// breakerOutcomeOf classifies an attempt for the circuit breaker: an attempt
+// that got no response is a failure, even when the CheckRetry policy does not
+// retry it, e.g. for a host that does not exist, and so is whatever the
+// policy retries.
+func breakerOutcomeOf(ctx context.Context, err error, retry bool) breakerOutcome {
+	switch {
+	case ctx.Err() != nil:
+		return breakerIgnored
+	case err != nil, retry:
+		return breakerFailure
+	}
+	return breakerSuccess
+}
+
+// releaseOnClose defers the given cancel functions until the body of resp is
+// closed, so the contexts of the request stay alive while it is being read.
+// Without a body they are called right away.
+func releaseOnClose(resp *http.Response, cancels ...context.CancelFunc) *http.Response {
+	release := func() {
+		for _, cancel := range cancels {
+			cancel()
+		}
+	}
+	if resp == nil || resp.Body == nil {
+		release()
+		return resp
+	}
+	resp.Body = &releaseBody{ReadCloser: resp.Body, release: release}
+	return resp
+}
+
+// releaseBody calls release once the wrapped body is closed.
+type releaseBody struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
 }
 
 // wrapBody wraps a body in a ReadCloser.
@@ -121,13 +360,15 @@ func wrapBody(body io.Reader) io.ReadCloser {
 	return io.NopCloser(body)
 }
 
-// drainBody reads the response body to reuse connections.
-func (c *Client) drainBody(req *Request, resp *http.Response) {
-	_, err := io.Copy(io.Discard, io.LimitReader(resp.Body, c.options.RespReadLimit))
+// drainBody reads up to limit bytes of the response body to reuse
+// connections and returns the number of bytes discarded.
+func (c *Client) drainBody(req *Request, resp *http.Response, limit int64) int64 {
+	n, err := io.Copy(io.Discard, io.LimitReader(resp.Body, limit))
 	if err != nil {
 		req.Metrics.DrainErrors++
 	}
 	resp.Body.Close()
+	return n
 }
 
 func (c *Client) closeIdleConnections() {
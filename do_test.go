@@ -1,7 +1,12 @@
 package httpify
 
 import (
+	"context"
+	"errors"
+	"io"
 	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -40,3 +45,206 @@ func TestDoWithRetry(t *testing.T) {
 	assert.Nil(t, resp)
 }
 
+func TestDoRetriesOnStatus(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewClient(Options{RetryMax: 3, Timeout: 5 * time.Second, RespReadLimit: 4096})
+	req, _ := NewRequest(http.MethodGet, server.URL, nil)
+
+	resp, err := client.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	assert.Equal(t, 2, req.Metrics.Retries)
+}
+
+func TestDoGivesUpOnStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	client := NewClient(Options{RetryMax: 2, Timeout: 5 * time.Second, RespReadLimit: 4096})
+	req, _ := NewRequest(http.MethodGet, server.URL, nil)
+
+	resp, err := client.Do(req)
+	assert.Nil(t, resp)
+	assert.ErrorContains(t, err, "giving up after 3 attempts")
+	assert.ErrorContains(t, err, "502")
+}
+
+func TestDoReturnsTerminalStatusWithoutError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("missing"))
+	}))
+	defer server.Close()
+
+	client := NewClient(Options{RetryMax: 2, Timeout: 5 * time.Second, TotalTimeout: 5 * time.Second, RespReadLimit: 4096})
+	// A policy explaining why it stops must not turn the response into an
+	// error the caller would never close.
+	client.CheckRetry = func(ctx context.Context, resp *http.Response, err error) (bool, error) {
+		return false, errors.New("not retryable")
+	}
+	req, _ := NewRequest(http.MethodGet, server.URL, nil)
+
+	resp, err := client.Do(req)
+	if assert.Nil(t, err) {
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		assert.Equal(t, "missing", string(body))
+	}
+	assert.Len(t, req.Metrics.Attempts, 1)
+}
+
+func TestDoErrorHandlerGetsLastResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := NewClient(Options{RetryMax: 1, Timeout: 5 * time.Second, RespReadLimit: 4096})
+	var gotErr error
+	var tries int
+	client.ErrorHandler = func(resp *http.Response, err error, numTries int) (*http.Response, error) {
+		gotErr, tries = err, numTries
+		return PassthroughErrorHandler(resp, err, numTries)
+	}
+	req, _ := NewRequest(http.MethodGet, server.URL, nil)
+
+	resp, err := client.Do(req)
+	assert.Nil(t, err)
+	assert.Nil(t, gotErr)
+	assert.Equal(t, 2, tries)
+	if assert.NotNil(t, resp) {
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		resp.Body.Close()
+	}
+}
+
+func TestDoRecordsAttempts(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("try again"))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewClient(Options{RetryMax: 3, RetryWaitMin: time.Millisecond, RetryWaitMax: time.Millisecond, Timeout: 5 * time.Second, RespReadLimit: 4096})
+	req, _ := NewRequest(http.MethodGet, server.URL, nil)
+
+	_, err := client.Do(req)
+	assert.Nil(t, err)
+
+	attempts := req.Metrics.Attempts
+	assert.Len(t, attempts, 2)
+	assert.Equal(t, http.StatusServiceUnavailable, attempts[0].StatusCode)
+	assert.Equal(t, int64(len("try again")), attempts[0].Drained)
+	assert.Equal(t, time.Millisecond, attempts[0].Wait)
+	assert.Equal(t, server.Listener.Addr().String(), attempts[0].RemoteAddr)
+	assert.False(t, attempts[0].Start.IsZero())
+	assert.Equal(t, http.StatusOK, attempts[1].StatusCode)
+	assert.Zero(t, attempts[1].Wait)
+}
+
+func TestDoReturnsTypedErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := NewClient(Options{RetryMax: 1, Timeout: 5 * time.Second, RespReadLimit: 4096})
+	req, _ := NewRequest(http.MethodGet, server.URL, nil)
+
+	_, err := client.Do(req)
+	var exhausted *RetriesExhaustedError
+	if assert.ErrorAs(t, err, &exhausted) {
+		assert.Equal(t, http.MethodGet, exhausted.Method)
+		assert.Equal(t, server.URL, exhausted.URL)
+		assert.Equal(t, 2, exhausted.Attempts)
+		assert.Equal(t, http.StatusServiceUnavailable, exhausted.StatusCode)
+		assert.Len(t, exhausted.History, 2)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, _ = NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	_, err = client.Do(req)
+	var aborted *RetryAbortedError
+	assert.ErrorAs(t, err, &aborted)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestDoTotalTimeoutBoundsAllAttempts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := NewClient(Options{
+		RetryMax:      100,
+		RetryWaitMin:  50 * time.Millisecond,
+		RetryWaitMax:  50 * time.Millisecond,
+		Timeout:       5 * time.Second,
+		TotalTimeout:  300 * time.Millisecond,
+		RespReadLimit: 4096,
+	})
+	req, _ := NewRequest(http.MethodGet, server.URL, nil)
+
+	start := time.Now()
+	_, err := client.Do(req)
+	assert.Less(t, time.Since(start), time.Second)
+
+	var exhausted *RetriesExhaustedError
+	if assert.ErrorAs(t, err, &exhausted) {
+		assert.Less(t, exhausted.Attempts, 10)
+	}
+}
+
+func TestDoAttemptTimeout(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+			return
+		}
+		w.Write([]byte("hello"))
+	}))
+	defer server.Close()
+
+	client := NewClient(Options{
+		RetryMax:       2,
+		Timeout:        5 * time.Second,
+		AttemptTimeout: 100 * time.Millisecond,
+		RespReadLimit:  4096,
+	})
+	req, _ := NewRequest(http.MethodGet, server.URL, nil)
+
+	resp, err := client.Do(req)
+	if assert.Nil(t, err) {
+		// The attempt context must stay alive until the body is consumed.
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Nil(t, err)
+		assert.Equal(t, "hello", string(body))
+	}
+	assert.Len(t, req.Metrics.Attempts, 2)
+	assert.ErrorIs(t, req.Metrics.Attempts[0].Err, context.DeadlineExceeded)
+}
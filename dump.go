@@ -0,0 +1,247 @@
+package httpify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"sync"
+)
+
+// DefaultDumpBodyLimit is the number of body bytes dumped when
+// DumpOptions.BodyLimit is zero.
+const DefaultDumpBodyLimit = 64 << 10
+
+// DumpOptions enables a wire dump of the request and response of every
+// attempt. Headers, URLs and errors are redacted by the Redactor of the
+// client, bodies are dumped as is. Request bodies are copied as they are
+// sent and response bodies as the caller reads them, never consumed for the
+// dump; the latter are dumped once closed. Failures to write a dump are
+// ignored.
+type DumpOptions struct {
+	// Writer receives the dumps of every request.
+	Writer io.Writer
+	// Dir, when Writer is nil, receives one file per Do call, named
+	// httpify-*.dump.
+	Dir string
+	// BodyLimit is the number of bytes dumped of each body, the rest being
+	// truncated. Zero means DefaultDumpBodyLimit and a negative value leaves
+	// bodies out.
+	BodyLimit int64
+
+	// mu serializes the blocks written to Writer.
+	mu sync.Mutex
+}
+
+// dumper writes the dumps of a Do call.
+type dumper struct {
+	w        io.Writer
+	mu       *sync.Mutex
+	limit    int64
+	redactor *Redactor
+	// attempts counts the attempts dumped so far.
+	attempts int
+}
+
+// newDumper returns the dumper of a Do call, nil when dumps are disabled or
+// the file cannot be created.
+func (c *Client) newDumper() *dumper {
+	options := c.Dump
+	if options == nil {
+		return nil
+	}
+	d := &dumper{w: options.Writer, mu: &options.mu, limit: options.BodyLimit, redactor: c.redactor()}
+	if d.limit == 0 {
+		d.limit = DefaultDumpBodyLimit
+	}
+	if d.w == nil {
+		if options.Dir == "" {
+			return nil
+		}
+		file, err := os.CreateTemp(options.Dir, "httpify-*.dump")
+		if err != nil {
+			return nil
+		}
+		file.Close()
+		d.w = appendFile(file.Name())
+	}
+	return d
+}
+
+// write writes a block of the dump at once.
+func (d *dumper) write(block []byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.w.Write(block)
+}
+
+// wrap dumps every attempt sent through next. The request body is copied as
+// next reads it, so the dump shows the body of the request next is given,
+// and the request is dumped once next returns, ahead of the response.
+func (d *dumper) wrap(next AttemptFunc) AttemptFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		d.attempts++
+		n := d.attempts
+		var body *dumpRequestBody
+		if d.limit > 0 && req.Body != nil && req.Body != http.NoBody {
+			body = &dumpRequestBody{ReadCloser: req.Body, limit: d.limit}
+			teed := *req
+			teed.Body = body
+			req = &teed
+		}
+		resp, err := next(req)
+		d.request(req, body, n)
+		d.response(req, n, resp, err)
+		return resp, err
+	}
+}
+
+// request dumps attempt n of req, with what was read so far of its body.
+func (d *dumper) request(req *http.Request, body *dumpRequestBody, n int) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, ">>> %s %s (attempt %d)\n", req.Method, d.redactor.URL(req.URL), n)
+
+	// The context of req carries the trace of the attempt, which must not see
+	// the connection faked by DumpRequestOut.
+	out := req.Clone(context.Background())
+	out.Header = d.redactor.Header(req.Header)
+	head, err := httputil.DumpRequestOut(out, false)
+	if err != nil {
+		fmt.Fprintf(&b, "dump failed: %v\n\n", d.redactor.String(err.Error()))
+		d.write(b.Bytes())
+		return
+	}
+	b.WriteString(d.redactor.String(string(head)))
+
+	if body != nil {
+		data, truncated := body.copied()
+		b.Write(data)
+		b.WriteString("\n")
+		if truncated > 0 {
+			fmt.Fprintf(&b, "[%d bytes truncated]\n", truncated)
+		}
+	}
+	b.WriteString("\n")
+	d.write(b.Bytes())
+}
+
+// dumpRequestBody copies what the transport reads from a request body, up to
+// the limit of the dumper. The transport may read it from another goroutine.
+type dumpRequestBody struct {
+	io.ReadCloser
+	limit int64
+
+	mu        sync.Mutex
+	buf       bytes.Buffer
+	truncated int64
+}
+
+func (b *dumpRequestBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.mu.Lock()
+		keep := min(int64(n), b.limit-int64(b.buf.Len()))
+		b.buf.Write(p[:keep])
+		b.truncated += int64(n) - keep
+		b.mu.Unlock()
+	}
+	return n, err
+}
+
+// copied returns a copy of the bytes read so far and the number of those
+// beyond the limit.
+func (b *dumpRequestBody) copied() ([]byte, int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return bytes.Clone(b.buf.Bytes()), b.truncated
+}
+
+// response dumps the headers of the response to attempt n, or its error, and
+// arranges for the body to be dumped as it is read.
+func (d *dumper) response(req *http.Request, n int, resp *http.Response, err error) {
+	var b bytes.Buffer
+	if err != nil {
+		fmt.Fprintf(&b, "<<< %s %s (attempt %d)\nerror: %s\n\n", req.Method, d.redactor.URL(req.URL), n, d.redactor.String(err.Error()))
+		d.write(b.Bytes())
+		return
+	}
+
+	fmt.Fprintf(&b, "<<< %s %s (attempt %d)\n", req.Method, d.redactor.URL(req.URL), n)
+	header := resp.Header
+	resp.Header = d.redactor.Header(header)
+	head, dumpErr := httputil.DumpResponse(resp, false)
+	resp.Header = header
+	if dumpErr != nil {
+		fmt.Fprintf(&b, "dump failed: %v\n", dumpErr)
+	} else {
+		b.WriteString(d.redactor.String(string(head)))
+	}
+	if d.limit < 0 || resp.Body == nil || resp.Body == http.NoBody {
+		b.WriteString("\n")
+		d.write(b.Bytes())
+		return
+	}
+	d.write(b.Bytes())
+
+	resp.Body = &dumpBody{
+		ReadCloser: resp.Body,
+		dumper:     d,
+		header:     fmt.Sprintf("<<< body of %s %s (attempt %d)\n", req.Method, d.redactor.URL(req.URL), n),
+	}
+}
+
+// dumpBody copies what the caller reads from a response body, up to the limit
+// of the dumper, and writes it once the body is closed.
+type dumpBody struct {
+	io.ReadCloser
+	dumper    *dumper
+	header    string
+	buf       bytes.Buffer
+	truncated int64
+	once      sync.Once
+}
+
+func (b *dumpBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		keep := min(int64(n), b.dumper.limit-int64(b.buf.Len()))
+		b.buf.Write(p[:keep])
+		b.truncated += int64(n) - keep
+	}
+	return n, err
+}
+
+func (b *dumpBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() {
+		var out bytes.Buffer
+		out.WriteString(b.header)
+		out.Write(b.buf.Bytes())
+		out.WriteString("\n")
+		if b.truncated > 0 {
+			fmt.Fprintf(&out, "[%d bytes truncated]\n", b.truncated)
+		}
+		out.WriteString("\n")
+		b.dumper.write(out.Bytes())
+	})
+	return err
+}
+
+// appendFile is a Writer appending to a file, opened for every write so that
+// no file is left open between attempts.
+type appendFile string
+
+func (f appendFile) Write(p []byte) (int, error) {
+	file, err := os.OpenFile(string(f), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return 0, err
+	}
+	n, err := file.Write(p)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return n, err
+}
@@ -0,0 +1,129 @@
+package httpify
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoDump(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=secret")
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			io.WriteString(w, "upstream down")
+			return
+		}
+		io.WriteString(w, strings.Repeat("a", 20))
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	client := NewClient(Options{RetryMax: 1, RespReadLimit: 4096})
+	client.Dump = &DumpOptions{Writer: &buf, BodyLimit: 16}
+
+	req, _ := NewRequest(http.MethodPut, server.URL+"/submit?access_token=abc", strings.NewReader(`{"name":"`+strings.Repeat("x", 30)+`"}`))
+	req.Header.Set("Authorization", "Bearer abc")
+	resp, err := client.Do(req)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	// The caller still gets the whole body.
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, strings.Repeat("a", 20), string(body))
+	resp.Body.Close()
+
+	dump := buf.String()
+	for _, want := range []string{
+		"PUT /submit?access_token=REDACTED HTTP/1.1",
+		"Authorization: REDACTED",
+		`{"name":"xxxxxxx` + "\n[25 bytes truncated]",
+		"(attempt 2)",
+		"HTTP/1.1 502 Bad Gateway",
+		"Set-Cookie: REDACTED",
+		"upstream down",
+		strings.Repeat("a", 16) + "\n[4 bytes truncated]",
+	} {
+		assert.Contains(t, dump, want)
+	}
+	assert.NotContains(t, dump, "abc")
+	assert.NotContains(t, dump, "secret")
+	assert.Equal(t, 2, strings.Count(dump, ">>> PUT"))
+}
+
+func TestDoDumpShowsMiddlewareBody(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = string(body)
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	client := NewClient(Options{RespReadLimit: 4096})
+	client.Dump = &DumpOptions{Writer: &buf}
+	client.AttemptMiddlewares = []AttemptMiddleware{func(next AttemptFunc) AttemptFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			body, _ := io.ReadAll(req.Body)
+			req.Body.Close()
+			signed := "signed:" + string(body)
+			req.Body, req.ContentLength = io.NopCloser(strings.NewReader(signed)), int64(len(signed))
+			return next(req)
+		}
+	}}
+
+	req, _ := NewRequest(http.MethodPost, server.URL, strings.NewReader("payload"))
+	var reads int32
+	body := req.body
+	req.body = func() (io.Reader, error) {
+		atomic.AddInt32(&reads, 1)
+		return body()
+	}
+	resp, err := client.Do(req)
+	if assert.Nil(t, err) {
+		resp.Body.Close()
+	}
+
+	assert.Equal(t, "signed:payload", received)
+	assert.Contains(t, buf.String(), "\r\n\r\nsigned:payload\n")
+	// The body is only made once per attempt.
+	assert.Equal(t, int32(1), atomic.LoadInt32(&reads))
+}
+
+func TestDoDumpToDir(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "hello")
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	client := NewClient(Options{})
+	client.Dump = &DumpOptions{Dir: dir, BodyLimit: -1}
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(server.URL)
+		if assert.Nil(t, err) {
+			io.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "httpify-*.dump"))
+	if assert.Len(t, files, 2) {
+		data, err := os.ReadFile(files[0])
+		assert.Nil(t, err)
+		assert.Contains(t, string(data), "GET / HTTP/1.1")
+		assert.Contains(t, string(data), "HTTP/1.1 200 OK")
+		assert.NotContains(t, string(data), "hello")
+	}
+}
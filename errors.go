@@ -0,0 +1,108 @@
+package httpify
+
+import "fmt"
+
+// ErrorDetails carries the request context shared by the errors returned by
+// Client.Do. Use errors.As with one of the concrete error types to get at it.
+type ErrorDetails struct {
+	// Method and URL identify the request.
+	Method string
+	URL    string
+	// Attempts is the number of attempts made before giving up.
+	Attempts int
+	// StatusCode is the status code of the last response, zero if none arrived.
+	StatusCode int
+	// Err is the last underlying error.
+	Err error
+	// redactor hides secrets in the message.
+	redactor *Redactor
+}
+
+// Unwrap returns the last underlying error.
+func (d ErrorDetails) Unwrap() error {
+	return d.Err
+}
+
+// RetriesExhaustedError is returned when every allowed attempt failed.
+type RetriesExhaustedError struct {
+	ErrorDetails
+	// History describes each attempt of the call.
+	History []Attempt
+}
+
+func (e *RetriesExhaustedError) Error() string {
+	return e.redactor.String(fmt.Sprintf("%s %s giving up after %d attempts: %v (%s)", e.Method, e.URL, e.Attempts, e.Err, summarizeAttempts(e.History)))
+}
+
+// RetryAbortedError is returned when the CheckRetry policy stops retrying a
+// failed request.
+type RetryAbortedError struct {
+	ErrorDetails
+}
+
+func (e *RetryAbortedError) Error() string {
+	return e.redactor.String(fmt.Sprintf("%s %s retry aborted after %d attempts: %v", e.Method, e.URL, e.Attempts, e.Err))
+}
+
+// BackoffCanceledError is returned when the request context is done while
+// waiting before an attempt, for a backoff or a host rate limit.
+type BackoffCanceledError struct {
+	ErrorDetails
+}
+
+func (e *BackoffCanceledError) Error() string {
+	return e.redactor.String(fmt.Sprintf("%s %s canceled while waiting after %d attempts: %v", e.Method, e.URL, e.Attempts, e.Err))
+}
+
+// BodyRewindError is returned when the request body cannot be recreated for
+// an attempt.
+type BodyRewindError struct {
+	ErrorDetails
+}
+
+func (e *BodyRewindError) Error() string {
+	return e.redactor.String(fmt.Sprintf("%s %s failed to rewind request body after %d attempts: %v", e.Method, e.URL, e.Attempts, e.Err))
+}
+
+// CircuitOpenError is returned when the circuit breaker of the client rejects
+// an attempt because the host keeps failing.
+type CircuitOpenError struct {
+	ErrorDetails
+	// Host is the host whose circuit is open.
+	Host string
+}
+
+func (e *CircuitOpenError) Error() string {
+	msg := fmt.Sprintf("%s %s circuit open for %s after %d attempts", e.Method, e.URL, e.Host, e.Attempts)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return e.redactor.String(msg)
+}
+
+// RetryBudgetExhaustedError is returned when a failed request is not retried
+// because the retry budget of the client is used up.
+type RetryBudgetExhaustedError struct {
+	ErrorDetails
+}
+
+func (e *RetryBudgetExhaustedError) Error() string {
+	return e.redactor.String(fmt.Sprintf("%s %s retry budget exhausted after %d attempts: %v", e.Method, e.URL, e.Attempts, e.Err))
+}
+
+// errorDetails builds the ErrorDetails of req from the attempts of a call,
+// with the URL and message redacted by the redactor of the client.
+func (c *Client) errorDetails(req *Request, history []Attempt, err error) ErrorDetails {
+	redactor := c.redactor()
+	details := ErrorDetails{
+		Method:   req.Method,
+		URL:      redactor.URL(req.URL),
+		Attempts: len(history),
+		Err:      err,
+		redactor: redactor,
+	}
+	if len(history) > 0 {
+		details.StatusCode = history[len(history)-1].StatusCode
+	}
+	return details
+}
@@ -0,0 +1,60 @@
+package httpify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorTypes(t *testing.T) {
+	cause := errors.New("connection refused")
+	details := ErrorDetails{Method: "GET", URL: "http://example.com", Attempts: 2, StatusCode: 503, Err: cause}
+
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{
+			name:     "Retries exhausted",
+			err:      &RetriesExhaustedError{details, []Attempt{{StatusCode: 503}, {Err: cause}}},
+			expected: "GET http://example.com giving up after 2 attempts: connection refused (#1 status 503 in 0s; #2 connection refused in 0s)",
+		},
+		{
+			name:     "Retry aborted",
+			err:      &RetryAbortedError{details},
+			expected: "GET http://example.com retry aborted after 2 attempts: connection refused",
+		},
+		{
+			name:     "Backoff canceled",
+			err:      &BackoffCanceledError{details},
+			expected: "GET http://example.com canceled while waiting after 2 attempts: connection refused",
+		},
+		{
+			name:     "Body rewind",
+			err:      &BodyRewindError{details},
+			expected: "GET http://example.com failed to rewind request body after 2 attempts: connection refused",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.err.Error())
+			assert.ErrorIs(t, tt.err, cause)
+		})
+	}
+}
+
+func TestErrorTypesAs(t *testing.T) {
+	var err error = fmt.Errorf("wrapped: %w", &BackoffCanceledError{ErrorDetails{Err: context.Canceled}})
+
+	var canceled *BackoffCanceledError
+	assert.True(t, errors.As(err, &canceled))
+	assert.ErrorIs(t, err, context.Canceled)
+
+	var exhausted *RetriesExhaustedError
+	assert.False(t, errors.As(err, &exhausted))
+}
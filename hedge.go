@@ -0,0 +1,204 @@
+package httpify
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// hedgeLatencySamples is the number of recent latencies a Hedger keeps to
+// derive its delay from, and hedgeMinSamples how many it needs to do so.
+const (
+	hedgeLatencySamples = 128
+	hedgeMinSamples     = 16
+)
+
+// HedgeOptions configures the hedged requests sent by Client.DoHedged.
+type HedgeOptions struct {
+	// Delay is how long to wait for a response before sending a hedge.
+	Delay time.Duration
+	// Percentile, between 0 and 1, derives the delay from the latency of
+	// recent requests instead, e.g. 0.95 hedges requests slower than the
+	// 95th percentile. Delay is used until enough requests completed.
+	Percentile float64
+	// MaxHedges is the number of duplicate requests that may be sent on top
+	// of the original one.
+	MaxHedges int
+	// AllowUnsafe allows hedging methods that are not safe, such as POST.
+	AllowUnsafe bool
+}
+
+// Hedger keeps the state of hedged requests, namely the latencies used to
+// compute a percentile based delay. It is safe for concurrent use.
+type Hedger struct {
+	options HedgeOptions
+
+	mu        sync.Mutex
+	latencies []time.Duration
+	next      int
+}
+
+// NewHedger returns a Hedger with the given options.
+func NewHedger(options HedgeOptions) *Hedger {
+	if options.MaxHedges <= 0 {
+		options.MaxHedges = 1
+	}
+	return &Hedger{options: options}
+}
+
+// delay returns how long to wait before sending the next hedge.
+func (h *Hedger) delay() time.Duration {
+	if h.options.Percentile <= 0 {
+		return h.options.Delay
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.latencies) < hedgeMinSamples {
+		return h.options.Delay
+	}
+	sorted := append([]time.Duration(nil), h.latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	idx := int(h.options.Percentile * float64(len(sorted)-1))
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
+}
+
+// observe records the latency of a completed request.
+func (h *Hedger) observe(latency time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.latencies) < hedgeLatencySamples {
+		h.latencies = append(h.latencies, latency)
+		return
+	}
+	h.latencies[h.next] = latency
+	h.next = (h.next + 1) % hedgeLatencySamples
+}
+
+// hedgeResult is the outcome of one of the copies sent by DoHedged.
+type hedgeResult struct {
+	req    *Request
+	resp   *http.Response
+	err    error
+	cancel context.CancelFunc
+	index  int
+	start  time.Time
+}
+
+// DoHedged sends req like Do, and sends duplicates of it whenever no response
+// arrived within the delay of the client's Hedger. The first successful
+// response wins; the responses of the other copies are drained and those
+// still in flight are cancelled.
+// The metrics of every copy are merged into those of req. Without a Hedger,
+// or for unsafe methods unless explicitly allowed, it is the same as Do.
+func (c *Client) DoHedged(req *Request) (*http.Response, error) {
+	h := c.Hedger
+	if h == nil || (!isSafeMethod(req.Method) && !h.options.AllowUnsafe) {
+		return c.Do(req)
+	}
+
+	// Every copy must carry the same key for the server to deduplicate them.
+	if err := c.setIdempotencyKey(req); err != nil {
+		return nil, err
+	}
+
+	clock := c.clock()
+	results := make(chan hedgeResult, 1+h.options.MaxHedges)
+	var cancels []context.CancelFunc
+	launch := func() {
+		ctx, cancel := context.WithCancel(req.Context())
+		cancels = append(cancels, cancel)
+		r := hedgeResult{req: req.clone(ctx), cancel: cancel, index: len(cancels) - 1, start: clock.Now()}
+		go func() {
+			r.resp, r.err = c.Do(r.req)
+			results <- r
+		}()
+	}
+
+	launch()
+	sent, pending := 1, 1
+	timer := clock.NewTimer(h.delay())
+	defer timer.Stop()
+
+	var winner *hedgeResult
+	var lastErr error
+	for winner == nil && pending > 0 {
+		select {
+		case <-timer.C():
+			if sent <= h.options.MaxHedges {
+				launch()
+				sent++
+				pending++
+				req.Metrics.Hedges++
+				timer.Reset(h.delay())
+			}
+		case r := <-results:
+			pending--
+			if r.err != nil {
+				// An ErrorHandler may return a response along with the error.
+				c.drainLoser(req, r)
+				lastErr = r.err
+				continue
+			}
+			req.Metrics.merge(r.req.Metrics)
+			winner = &r
+		}
+	}
+
+	// Losers that already answered are drained to reuse their connections
+	// before the others are cancelled.
+collect:
+	for pending > 0 {
+		select {
+		case r := <-results:
+			pending--
+			c.drainLoser(req, r)
+		default:
+			break collect
+		}
+	}
+	for i, cancel := range cancels {
+		if winner == nil || i != winner.index {
+			cancel()
+		}
+	}
+	// The cancelled losers are waited for, so that the attempts they made
+	// are part of the metrics. Their responses can no longer be read.
+	for ; pending > 0; pending-- {
+		r := <-results
+		if r.resp != nil {
+			r.resp.Body.Close()
+		}
+		req.Metrics.merge(r.req.Metrics)
+	}
+
+	if winner == nil {
+		return nil, lastErr
+	}
+	h.observe(clock.Now().Sub(winner.start))
+	return releaseOnClose(winner.resp, winner.cancel), nil
+}
+
+// drainLoser drains the response of a copy that did not win, if any, and
+// merges its metrics into those of req.
+func (c *Client) drainLoser(req *Request, r hedgeResult) {
+	if r.resp != nil {
+		c.drainBody(r.req, r.resp, c.settingsFor(r.req).RespReadLimit)
+	}
+	req.Metrics.merge(r.req.Metrics)
+}
+
+// isSafeMethod reports whether method is safe as defined by RFC 9110, so
+// sending it more than once has no side effects.
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+package httpify
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoHedged(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(2 * time.Second):
+			}
+			return
+		}
+		w.Write([]byte("fast"))
+	}))
+	defer server.Close()
+
+	client := NewClient(Options{RetryMax: 0, Timeout: 5 * time.Second, RespReadLimit: 4096})
+	client.Hedger = NewHedger(HedgeOptions{Delay: 50 * time.Millisecond, MaxHedges: 1})
+
+	req, _ := NewRequest(http.MethodGet, server.URL, nil)
+	start := time.Now()
+	resp, err := client.DoHedged(req)
+	if assert.Nil(t, err) {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, "fast", string(body))
+	}
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, 1, req.Metrics.Hedges)
+	// The attempt of the cancelled copy is counted too.
+	if assert.Len(t, req.Metrics.Attempts, 2) {
+		assert.Equal(t, http.StatusOK, req.Metrics.Attempts[0].StatusCode)
+		assert.ErrorIs(t, req.Metrics.Attempts[1].Err, context.Canceled)
+	}
+	assert.Equal(t, 1, req.Metrics.Failures)
+}
+
+func TestDoHedgedClosesFailedResponses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(30 * time.Millisecond)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	var returned, closed int32
+	client := NewClient(Options{RetryMax: 0, Timeout: 5 * time.Second, RespReadLimit: 4096})
+	client.Hedger = NewHedger(HedgeOptions{Delay: 10 * time.Millisecond, MaxHedges: 1})
+	// The handler hands back the response along with the error.
+	client.ErrorHandler = func(resp *http.Response, err error, numTries int) (*http.Response, error) {
+		atomic.AddInt32(&returned, 1)
+		resp.Body = closeCounter{resp.Body, &closed}
+		return resp, errors.New("exhausted")
+	}
+
+	req, _ := NewRequest(http.MethodGet, server.URL, nil)
+	resp, err := client.DoHedged(req)
+	assert.Nil(t, resp)
+	assert.EqualError(t, err, "exhausted")
+	assert.Equal(t, int32(2), atomic.LoadInt32(&returned))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&closed))
+}
+
+// closeCounter counts the calls to Close of its body.
+type closeCounter struct {
+	io.ReadCloser
+	closed *int32
+}
+
+func (c closeCounter) Close() error {
+	atomic.AddInt32(c.closed, 1)
+	return c.ReadCloser.Close()
+}
+
+func TestDoHedgedSkipsUnsafeMethods(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	client := NewClient(Options{RetryMax: 0, Timeout: 5 * time.Second, RespReadLimit: 4096})
+	client.Hedger = NewHedger(HedgeOptions{Delay: 10 * time.Millisecond, MaxHedges: 2})
+
+	req, _ := NewRequest(http.MethodPost, server.URL, nil)
+	resp, err := client.DoHedged(req)
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	assert.Zero(t, req.Metrics.Hedges)
+}
+
+func TestHedgerPercentileDelay(t *testing.T) {
+	hedger := NewHedger(HedgeOptions{Delay: time.Second, Percentile: 0.9})
+	assert.Equal(t, time.Second, hedger.delay())
+
+	for i := 1; i <= 100; i++ {
+		hedger.observe(time.Duration(i) * time.Millisecond)
+	}
+	assert.Equal(t, 90*time.Millisecond, hedger.delay())
+}
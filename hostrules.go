@@ -0,0 +1,337 @@
+package httpify
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HostRule overrides client settings for the requests to matching hosts.
+type HostRule struct {
+	// Pattern selects hosts. It is either a regular expression prefixed with
+	// "re:", a CIDR such as "10.0.0.0/8" matching IP hosts, or a glob such as
+	// "*.internal.example.com" following the syntax of path.Match. Matching
+	// ignores case and the port.
+	Pattern string
+	// Overrides replaces client settings. Request.Overrides still wins.
+	Overrides Overrides
+	// RateLimit caps the number of attempts per second to all the hosts
+	// matching the rule, zero meaning no limit. Burst is the number of
+	// attempts allowed at once, at least one.
+	RateLimit float64
+	Burst     int
+	// Header is added to matching requests, except for the keys they already
+	// carry.
+	Header http.Header
+	// Proxy is the URL of the proxy to send matching requests through.
+	Proxy string
+	// TLSConfig replaces the TLS configuration used for matching requests.
+	TLSConfig *tls.Config
+	// TLS describes the TLS configuration instead, as in configuration files.
+	// Only one of TLS and TLSConfig may be set.
+	TLS *TLSOptions
+}
+
+// HostRules is an ordered list of compiled HostRule. The first rule matching
+// the host of a request applies to it. It is safe for concurrent use.
+type HostRules struct {
+	rules []*hostRule
+}
+
+type hostRule struct {
+	HostRule
+	match    func(host string) bool
+	limiter  *rateLimiter
+	proxyURL *url.URL
+	// reloader reloads the files of TLS, if any.
+	reloader *certReloader
+
+	// clients caches the HTTP client derived for every base client when the
+	// rule changes the transport.
+	mu      sync.Mutex
+	clients map[*http.Client]*http.Client
+}
+
+// NewHostRules compiles rules, keeping their order.
+func NewHostRules(rules ...HostRule) (*HostRules, error) {
+	compiled := make([]*hostRule, 0, len(rules))
+	for _, rule := range rules {
+		match, err := compileHostPattern(rule.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		hr := &hostRule{HostRule: rule, match: match}
+		if rule.RateLimit > 0 {
+			hr.limiter = newRateLimiter(rule.RateLimit, rule.Burst)
+		}
+		if rule.Proxy != "" {
+			if hr.proxyURL, err = url.Parse(rule.Proxy); err != nil {
+				return nil, fmt.Errorf("host rule %q: invalid proxy: %w", rule.Pattern, err)
+			}
+		}
+		if rule.TLS != nil {
+			if rule.TLSConfig != nil {
+				return nil, fmt.Errorf("host rule %q: only one of TLS and TLSConfig may be set", rule.Pattern)
+			}
+			if hr.TLSConfig, hr.reloader, err = rule.TLS.config(); err != nil {
+				return nil, fmt.Errorf("host rule %q: %w", rule.Pattern, err)
+			}
+		}
+		compiled = append(compiled, hr)
+	}
+	return &HostRules{rules: compiled}, nil
+}
+
+// hostRuleConfig is the form of a HostRule in a configuration file.
+type hostRuleConfig struct {
+	Pattern        string            `json:"pattern"`
+	RetryMax       *int              `json:"retry_max"`
+	RetryWaitMin   string            `json:"retry_wait_min"`
+	RetryWaitMax   string            `json:"retry_wait_max"`
+	TotalTimeout   string            `json:"total_timeout"`
+	AttemptTimeout string            `json:"attempt_timeout"`
+	RespReadLimit  int64             `json:"resp_read_limit"`
+	RateLimit      float64           `json:"rate_limit"`
+	Burst          int               `json:"burst"`
+	Headers        map[string]string `json:"headers"`
+	Proxy          string            `json:"proxy"`
+	TLS            *TLSOptions       `json:"tls"`
+}
+
+// LoadHostRules reads rules from a JSON file of the form
+//
+//	{"rules": [
+//		{"pattern": "*.payments.internal", "retry_max": 1, "attempt_timeout": "2s"},
+//		{"pattern": "10.0.0.0/8", "rate_limit": 50, "burst": 10, "proxy": "http://proxy:3128"},
+//		{"pattern": "*.partner.com", "tls": {"root_ca_files": ["partner.pem"], "min_version": "1.2"}}
+//	]}
+//
+// Durations use the syntax of time.ParseDuration.
+func LoadHostRules(filename string) (*HostRules, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Rules []hostRuleConfig `json:"rules"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	rules := make([]HostRule, 0, len(file.Rules))
+	for _, cfg := range file.Rules {
+		rule, err := cfg.hostRule()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+		rules = append(rules, rule)
+	}
+	return NewHostRules(rules...)
+}
+
+func (cfg hostRuleConfig) hostRule() (HostRule, error) {
+	rule := HostRule{
+		Pattern:   cfg.Pattern,
+		RateLimit: cfg.RateLimit,
+		Burst:     cfg.Burst,
+		Proxy:     cfg.Proxy,
+		TLS:       cfg.TLS,
+		Overrides: Overrides{
+			RetryMax:      cfg.RetryMax,
+			RespReadLimit: cfg.RespReadLimit,
+		},
+	}
+	durations := []struct {
+		value string
+		dst   *time.Duration
+	}{
+		{cfg.RetryWaitMin, &rule.Overrides.RetryWaitMin},
+		{cfg.RetryWaitMax, &rule.Overrides.RetryWaitMax},
+		{cfg.TotalTimeout, &rule.Overrides.TotalTimeout},
+		{cfg.AttemptTimeout, &rule.Overrides.AttemptTimeout},
+	}
+	for _, d := range durations {
+		if d.value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(d.value)
+		if err != nil {
+			return rule, fmt.Errorf("host rule %q: %w", cfg.Pattern, err)
+		}
+		*d.dst = parsed
+	}
+	if len(cfg.Headers) > 0 {
+		rule.Header = make(http.Header, len(cfg.Headers))
+		for k, v := range cfg.Headers {
+			rule.Header.Set(k, v)
+		}
+	}
+	return rule, nil
+}
+
+// match returns the first rule matching host, or nil.
+func (r *HostRules) match(host string) *hostRule {
+	if r == nil {
+		return nil
+	}
+	host = strings.ToLower(host)
+	for _, rule := range r.rules {
+		if rule.match(host) {
+			return rule
+		}
+	}
+	return nil
+}
+
+func compileHostPattern(pattern string) (func(host string) bool, error) {
+	if expr, ok := strings.CutPrefix(pattern, "re:"); ok {
+		re, err := regexp.Compile("(?i)" + expr)
+		if err != nil {
+			return nil, fmt.Errorf("host rule %q: %w", pattern, err)
+		}
+		return re.MatchString, nil
+	}
+
+	if strings.Contains(pattern, "/") {
+		_, network, err := net.ParseCIDR(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("host rule %q: %w", pattern, err)
+		}
+		return func(host string) bool {
+			ip := net.ParseIP(host)
+			return ip != nil && network.Contains(ip)
+		}, nil
+	}
+
+	pattern = strings.ToLower(pattern)
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("host rule %q: %w", pattern, err)
+	}
+	return func(host string) bool {
+		ok, _ := path.Match(pattern, host)
+		return ok
+	}, nil
+}
+
+// setHeader adds the headers of the rule that req does not carry yet.
+func (r *hostRule) setHeader(req *Request) {
+	for key, values := range r.Header {
+		if _, ok := req.Header[key]; ok {
+			continue
+		}
+		if req.Header == nil {
+			req.Header = make(http.Header)
+		}
+		req.Header[key] = append([]string(nil), values...)
+	}
+}
+
+// httpClient returns base, or a copy of it using the proxy and TLS settings
+// of the rule. The copy is built once per base client.
+func (r *hostRule) httpClient(base *http.Client) (*http.Client, error) {
+	if r.proxyURL == nil && r.TLSConfig == nil {
+		return base, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if client, ok := r.clients[base]; ok {
+		return client, nil
+	}
+
+	transport, ok := base.Transport.(*http.Transport)
+	if !ok {
+		return nil, fmt.Errorf("host rule %q: proxy and TLS settings need an *http.Transport, got %T", r.Pattern, base.Transport)
+	}
+	transport = transport.Clone()
+	if r.proxyURL != nil {
+		transport.Proxy = http.ProxyURL(r.proxyURL)
+	}
+	if r.TLSConfig != nil {
+		// A TLS dialer of the base transport would not use the config.
+		transport.TLSClientConfig = r.TLSConfig.Clone()
+		transport.DialTLSContext = nil
+		r.reloader.attach(transport)
+	}
+
+	client := *base
+	client.Transport = transport
+	if r.clients == nil {
+		r.clients = make(map[*http.Client]*http.Client)
+	}
+	r.clients[base] = &client
+	return &client, nil
+}
+
+// rateLimiter is a token bucket refilled at rate tokens per second.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{rate: rate, burst: float64(burst), tokens: float64(burst)}
+}
+
+// reserve takes a token and returns how long to wait before using it.
+func (l *rateLimiter) reserve(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+	}
+	l.last = now
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// wait blocks until a token is available or ctx is done.
+func (l *rateLimiter) wait(ctx context.Context, clock Clock) error {
+	d := l.reserve(clock.Now())
+	if d <= 0 {
+		return nil
+	}
+	timer := clock.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		l.cancel()
+		return ctx.Err()
+	case <-timer.C():
+		return nil
+	}
+}
+
+// cancel gives back a token taken by reserve that will not be used.
+func (l *rateLimiter) cancel() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tokens++
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+}
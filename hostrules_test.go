@@ -0,0 +1,141 @@
+package httpify
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHostRulesMatch(t *testing.T) {
+	rules, err := NewHostRules(
+		HostRule{Pattern: "*.payments.internal"},
+		HostRule{Pattern: "re:^api[0-9]+\\.example\\.com$"},
+		HostRule{Pattern: "10.0.0.0/8"},
+		HostRule{Pattern: "*"},
+	)
+	assert.Nil(t, err)
+
+	tests := []struct {
+		host     string
+		expected string
+	}{
+		{"eu.payments.internal", "*.payments.internal"},
+		{"EU.Payments.Internal", "*.payments.internal"},
+		{"api12.example.com", "re:^api[0-9]+\\.example\\.com$"},
+		{"10.1.2.3", "10.0.0.0/8"},
+		{"192.168.1.1", "*"},
+		{"example.org", "*"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			rule := rules.match(tt.host)
+			if assert.NotNil(t, rule) {
+				assert.Equal(t, tt.expected, rule.Pattern)
+			}
+		})
+	}
+
+	var none *HostRules
+	assert.Nil(t, none.match("example.com"))
+}
+
+func TestNewHostRulesInvalid(t *testing.T) {
+	for _, pattern := range []string{"re:(", "10.0.0.0/99", "["} {
+		_, err := NewHostRules(HostRule{Pattern: pattern})
+		assert.NotNil(t, err, pattern)
+	}
+	_, err := NewHostRules(HostRule{Pattern: "*", Proxy: "://bad"})
+	assert.NotNil(t, err)
+}
+
+func TestLoadHostRules(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "rules.json")
+	err := os.WriteFile(filename, []byte(`{"rules": [
+		{"pattern": "*.internal", "retry_max": 1, "attempt_timeout": "2s", "headers": {"X-Team": "scan"}},
+		{"pattern": "10.0.0.0/8", "rate_limit": 50, "burst": 10, "proxy": "http://proxy:3128"}
+	]}`), 0o600)
+	assert.Nil(t, err)
+
+	rules, err := LoadHostRules(filename)
+	if assert.Nil(t, err) {
+		rule := rules.match("db.internal")
+		assert.Equal(t, 1, *rule.Overrides.RetryMax)
+		assert.Equal(t, 2*time.Second, rule.Overrides.AttemptTimeout)
+		assert.Equal(t, "scan", rule.Header.Get("X-Team"))
+
+		rule = rules.match("10.0.0.1")
+		assert.NotNil(t, rule.limiter)
+		assert.Equal(t, "proxy:3128", rule.proxyURL.Host)
+	}
+
+	assert.Nil(t, os.WriteFile(filename, []byte(`{"rules": [{"pattern": "*", "retry_wait_min": "soon"}]}`), 0o600))
+	_, err = LoadHostRules(filename)
+	assert.NotNil(t, err)
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter := newRateLimiter(2, 2)
+	now := time.Now()
+
+	assert.Zero(t, limiter.reserve(now))
+	assert.Zero(t, limiter.reserve(now))
+	assert.Equal(t, 500*time.Millisecond, limiter.reserve(now))
+	assert.Zero(t, limiter.reserve(now.Add(time.Second)))
+}
+
+func TestRateLimiterWaitCancelled(t *testing.T) {
+	limiter := newRateLimiter(1, 1)
+	clock := &steppedClock{now: time.Now()}
+	assert.Nil(t, limiter.wait(context.Background(), clock))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, limiter.wait(ctx, clock), context.Canceled)
+	// The token taken by the cancelled wait is given back, so the next one
+	// is due after a single interval.
+	assert.Equal(t, time.Second, limiter.reserve(clock.Now()))
+}
+
+func TestDoWithHostRules(t *testing.T) {
+	var calls int32
+	var team atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		team.Store(r.Header.Get("X-Team"))
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	retryMax := 0
+	rules, err := NewHostRules(HostRule{
+		Pattern:   "127.0.0.0/8",
+		Overrides: Overrides{RetryMax: &retryMax},
+		Header:    http.Header{"X-Team": {"scan"}},
+	})
+	assert.Nil(t, err)
+
+	client := NewClient(Options{RetryMax: 5, Timeout: 5 * time.Second, RespReadLimit: 4096})
+	client.HostRules = rules
+
+	req, _ := NewRequest(http.MethodGet, server.URL, nil)
+	_, err = client.Do(req)
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	assert.Equal(t, "scan", team.Load())
+
+	// Request overrides take precedence over the rule.
+	atomic.StoreInt32(&calls, 0)
+	retryMaxReq := 1
+	req, _ = NewRequest(http.MethodGet, server.URL, nil)
+	req.Overrides = &Overrides{RetryMax: &retryMaxReq}
+	_, err = client.Do(req)
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
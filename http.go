@@ -7,16 +7,32 @@ import (
 	"time"
 )
 
-// HostSprayingTransport returns a new http.Transport with disabled idle connections and keepalives.
+// NoKeepAliveTransport returns a new http.Transport with disabled idle connections and keepalives.
 func NoKeepAliveTransport() *http.Transport {
-	transport := PooledTransport()
+	return NoKeepAliveTransportWithTLS(nil)
+}
+
+// NoKeepAliveTransportWithTLS is like NoKeepAliveTransport with the given TLS configuration.
+func NoKeepAliveTransportWithTLS(tlsConfig *tls.Config) *http.Transport {
+	transport := PooledTransportWithTLS(tlsConfig)
 	transport.DisableKeepAlives = true
 	transport.MaxIdleConnsPerHost = -1
 	return transport
 }
 
-// PooledTransport returns a new http.Transport for connection reuse.
+// PooledTransport returns a new http.Transport for connection reuse. It verifies
+// certificates against the system roots.
 func PooledTransport() *http.Transport {
+	return PooledTransportWithTLS(nil)
+}
+
+// PooledTransportWithTLS is like PooledTransport with the given TLS configuration,
+// see TLSOptions.Config. A nil tlsConfig uses the zero TLSOptions.
+func PooledTransportWithTLS(tlsConfig *tls.Config) *http.Transport {
+	if tlsConfig == nil {
+		// The zero options refer to no file, so building them cannot fail.
+		tlsConfig, _ = TLSOptions{}.Config()
+	}
 	return &http.Transport{
 		Proxy: http.ProxyFromEnvironment,
 		DialContext: (&net.Dialer{
@@ -30,10 +46,7 @@ func PooledTransport() *http.Transport {
 		ExpectContinueTimeout:  1 * time.Second,
 		MaxIdleConnsPerHost:    100,
 		MaxResponseHeaderBytes: 4096, // Default is 10MB
-		TLSClientConfig: &tls.Config{
-			Renegotiation:      tls.RenegotiateOnceAsClient,
-			InsecureSkipVerify: true, // Optional, but unsafe
-		},
+		TLSClientConfig:        tlsConfig,
 	}
 }
 
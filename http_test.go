@@ -18,6 +18,7 @@ func TestDefaultReusePooledTransport(t *testing.T) {
 	assert.NotNil(t, transport)
 	assert.Equal(t, 100, transport.MaxIdleConnsPerHost)
 	assert.Equal(t, 90*time.Second, transport.IdleConnTimeout)
+	assert.False(t, transport.TLSClientConfig.InsecureSkipVerify)
 }
 
 func TestDefaultClient(t *testing.T) {
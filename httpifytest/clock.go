@@ -0,0 +1,142 @@
+// Package httpifytest provides helpers for testing code built on httpify.
+package httpifytest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cyinnove/httpify"
+)
+
+// FakeClock is an httpify.Clock whose time only moves when Advance is called.
+// It records the duration of every timer created, so tests can assert the
+// exact backoff sequence of a client.
+type FakeClock struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	timers  []*fakeTimer
+	created []time.Duration
+}
+
+// NewFakeClock returns a FakeClock set to now.
+func NewFakeClock(now time.Time) *FakeClock {
+	c := &FakeClock{now: now}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// Now returns the current fake time.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTimer returns a timer firing once the fake time advanced by d.
+func (c *FakeClock) NewTimer(d time.Duration) httpify.Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{clock: c, ch: make(chan time.Time, 1)}
+	c.created = append(c.created, d)
+	c.schedule(t, d)
+	return t
+}
+
+// Advance moves the fake time forward by d and fires the timers that are due.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+
+	sort.Slice(c.timers, func(i, j int) bool { return c.timers[i].when.Before(c.timers[j].when) })
+	pending := c.timers[:0]
+	for _, t := range c.timers {
+		if t.when.After(c.now) {
+			pending = append(pending, t)
+			continue
+		}
+		t.active = false
+		t.fire()
+	}
+	c.timers = pending
+}
+
+// BlockUntil blocks until at least n timers are waiting to fire, which lets
+// a test synchronize with code sleeping in another goroutine.
+func (c *FakeClock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.timers) < n {
+		c.cond.Wait()
+	}
+}
+
+// Timers returns the durations of every timer created so far, in order.
+func (c *FakeClock) Timers() []time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]time.Duration(nil), c.created...)
+}
+
+// schedule arms t to fire after d. It must be called with c.mu held.
+func (c *FakeClock) schedule(t *fakeTimer, d time.Duration) {
+	t.when = c.now.Add(d)
+	if d <= 0 {
+		t.fire()
+		return
+	}
+	t.active = true
+	c.timers = append(c.timers, t)
+	c.cond.Broadcast()
+}
+
+// unschedule disarms t. It must be called with c.mu held.
+func (c *FakeClock) unschedule(t *fakeTimer) bool {
+	if !t.active {
+		return false
+	}
+	t.active = false
+	for i, other := range c.timers {
+		if other == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+type fakeTimer struct {
+	clock  *FakeClock
+	ch     chan time.Time
+	when   time.Time
+	active bool
+}
+
+// fire delivers the time to a reader, dropping it if the previous one was
+// never received, like time.Timer does.
+func (t *fakeTimer) fire() {
+	select {
+	case t.ch <- t.when:
+	default:
+	}
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	return t.clock.unschedule(t)
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	wasActive := t.clock.unschedule(t)
+	t.clock.schedule(t, d)
+	return wasActive
+}
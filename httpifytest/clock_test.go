@@ -0,0 +1,108 @@
+package httpifytest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cyinnove/httpify"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+
+	short := clock.NewTimer(time.Second)
+	long := clock.NewTimer(time.Minute)
+
+	clock.Advance(2 * time.Second)
+	assert.Equal(t, start.Add(2*time.Second), clock.Now())
+	select {
+	case at := <-short.C():
+		assert.Equal(t, start.Add(time.Second), at)
+	default:
+		t.Fatal("expected the short timer to fire")
+	}
+
+	assert.True(t, long.Stop())
+	clock.Advance(time.Hour)
+	select {
+	case <-long.C():
+		t.Fatal("a stopped timer must not fire")
+	default:
+	}
+
+	assert.Equal(t, []time.Duration{time.Second, time.Minute}, clock.Timers())
+}
+
+func TestFakeClockBackoffSequence(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	clock := NewFakeClock(time.Now())
+	client := httpify.NewClient(httpify.Options{
+		RetryMax:      3,
+		RetryWaitMin:  time.Second,
+		RetryWaitMax:  time.Minute,
+		Timeout:       5 * time.Second,
+		RespReadLimit: 4096,
+	})
+	client.Clock = clock
+
+	done := make(chan error)
+	go func() {
+		req, _ := httpify.NewRequest(http.MethodGet, server.URL, nil)
+		_, err := client.Do(req)
+		done <- err
+	}()
+
+	for i := 0; i < 3; i++ {
+		clock.BlockUntil(1)
+		timers := clock.Timers()
+		clock.Advance(timers[len(timers)-1])
+	}
+
+	assert.NotNil(t, <-done)
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}, clock.Timers())
+}
+
+func TestFakeClockTotalTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	// The fake time is far from the real one, which the total timeout
+	// runs on.
+	clock := NewFakeClock(time.Now().Add(time.Hour))
+	client := httpify.NewClient(httpify.Options{
+		RetryMax:      2,
+		RetryWaitMin:  time.Second,
+		RetryWaitMax:  time.Minute,
+		Timeout:       5 * time.Second,
+		TotalTimeout:  time.Minute,
+		RespReadLimit: 4096,
+	})
+	client.Clock = clock
+
+	done := make(chan error)
+	req, _ := httpify.NewRequest(http.MethodGet, server.URL, nil)
+	go func() {
+		_, err := client.Do(req)
+		done <- err
+	}()
+
+	for i := 0; i < 2; i++ {
+		clock.BlockUntil(1)
+		timers := clock.Timers()
+		clock.Advance(timers[len(timers)-1])
+	}
+
+	assert.NotNil(t, <-done)
+	assert.Len(t, req.Metrics.Attempts, 3)
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, clock.Timers())
+}
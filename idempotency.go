@@ -0,0 +1,79 @@
+package httpify
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	mathrand "math/rand"
+	"net/http"
+)
+
+// IdempotencyKeyHeader is the header used to let servers deduplicate retried
+// requests.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentRetryPolicy wraps policy so that requests with a non-idempotent
+// method, such as POST, are only retried when they provably never reached the
+// server, i.e. no connection was obtained because dialing or the TLS handshake
+// failed. Requests carrying an Idempotency-Key header are retried like any
+// other since the server can deduplicate them.
+func IdempotentRetryPolicy(policy CheckRetry) CheckRetry {
+	return func(ctx context.Context, resp *http.Response, err error) (bool, error) {
+		retry, checkErr := policy(ctx, resp, err)
+		if !retry {
+			return retry, checkErr
+		}
+
+		req, attempt, ok := AttemptFromContext(ctx)
+		if !ok || isIdempotent(req.Method) || req.Header.Get(IdempotencyKeyHeader) != "" {
+			return retry, checkErr
+		}
+		if err != nil && !attempt.RequestWritten {
+			return retry, checkErr
+		}
+		return false, nil
+	}
+}
+
+// isIdempotent reports whether method is idempotent as defined by RFC 9110.
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return isSafeMethod(method)
+}
+
+// setIdempotencyKey adds a random Idempotency-Key header to requests with a
+// non-idempotent method that don't carry one yet. The header is set on the
+// request itself so it stays the same across all of its attempts.
+func (c *Client) setIdempotencyKey(req *Request) error {
+	if !c.options.IdempotencyKey || isIdempotent(req.Method) || req.Header.Get(IdempotencyKeyHeader) != "" {
+		return nil
+	}
+	key, err := newIdempotencyKey(c.Rand)
+	if err != nil {
+		return err
+	}
+	if req.Header == nil {
+		req.Header = make(http.Header)
+	}
+	req.Header.Set(IdempotencyKeyHeader, key)
+	return nil
+}
+
+// newIdempotencyKey returns a random version 4 UUID drawn from random, or from
+// crypto/rand when it is nil.
+func newIdempotencyKey(random *mathrand.Rand) (string, error) {
+	var b [16]byte
+	if random != nil {
+		binary.BigEndian.PutUint64(b[:8], random.Uint64())
+		binary.BigEndian.PutUint64(b[8:], random.Uint64())
+	} else if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
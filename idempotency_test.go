@@ -0,0 +1,78 @@
+package httpify
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotentRetryPolicyPost(t *testing.T) {
+	var keys []string
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		keys = append(keys, r.Header.Get(IdempotencyKeyHeader))
+		mu.Unlock()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := NewClient(Options{RetryMax: 2, Timeout: 5 * time.Second, RespReadLimit: 4096})
+	req, _ := NewRequest(http.MethodPost, server.URL, nil)
+	resp, err := client.Do(req)
+	assert.Nil(t, err)
+	if assert.NotNil(t, resp) {
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		resp.Body.Close()
+	}
+	assert.Equal(t, []string{""}, keys, "a POST that reached the server must not be retried")
+
+	keys = nil
+	client = NewClient(Options{RetryMax: 2, Timeout: 5 * time.Second, RespReadLimit: 4096, IdempotencyKey: true})
+	req, _ = NewRequest(http.MethodPost, server.URL, nil)
+	_, err = client.Do(req)
+	assert.NotNil(t, err)
+	if assert.Len(t, keys, 3) {
+		assert.Len(t, keys[0], 36)
+		assert.Equal(t, keys[0], keys[1])
+		assert.Equal(t, keys[0], keys[2])
+	}
+}
+
+func TestIdempotentRetryPolicyUnwrittenPost(t *testing.T) {
+	// Grab a free port and close it so that dialing fails.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	client := NewClient(Options{RetryMax: 2, Timeout: 5 * time.Second, RespReadLimit: 4096})
+	req, _ := NewRequest(http.MethodPost, "http://"+addr, nil)
+	_, err = client.Do(req)
+
+	var exhausted *RetriesExhaustedError
+	if assert.ErrorAs(t, err, &exhausted) {
+		assert.Equal(t, 3, exhausted.Attempts)
+		assert.False(t, exhausted.History[0].RequestWritten)
+	}
+}
+
+func TestIsIdempotent(t *testing.T) {
+	for method, expected := range map[string]bool{
+		http.MethodGet:    true,
+		http.MethodHead:   true,
+		http.MethodPut:    true,
+		http.MethodDelete: true,
+		http.MethodPost:   false,
+		http.MethodPatch:  false,
+	} {
+		assert.Equal(t, expected, isIdempotent(method), method)
+	}
+}
@@ -0,0 +1,168 @@
+package httpify
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Attribute keys of the records logged through Client.Logger and
+// SlogTraceSink.
+const (
+	LogKeyMethod     = "method"
+	LogKeyURL        = "url"
+	LogKeyAttempt    = "attempt"
+	LogKeyAttempts   = "attempts"
+	LogKeyStatus     = "status"
+	LogKeyError      = "error"
+	LogKeyErrorKind  = "error_kind"
+	LogKeyDuration   = "duration"
+	LogKeyWait       = "wait"
+	LogKeyReason     = "reason"
+	LogKeyRemoteAddr = "remote_addr"
+	LogKeyHeaders    = "headers"
+)
+
+// LogLevels sets the level of every event logged through Client.Logger.
+type LogLevels struct {
+	// AttemptStart is logged before each attempt, with the request headers.
+	AttemptStart slog.Level
+	// AttemptResult is logged after each attempt with its status or error.
+	AttemptResult slog.Level
+	// RetryScheduled is logged before waiting for the next attempt, with
+	// the wait and the reason for retrying.
+	RetryScheduled slog.Level
+	// GiveUp is logged when Do returns an error.
+	GiveUp slog.Level
+	// Success is logged when Do returns a response without error.
+	Success slog.Level
+}
+
+// DefaultLogLevels keeps the per attempt events at debug level and reports
+// retries and failures.
+var DefaultLogLevels = LogLevels{
+	AttemptStart:   slog.LevelDebug,
+	AttemptResult:  slog.LevelDebug,
+	RetryScheduled: slog.LevelInfo,
+	GiveUp:         slog.LevelWarn,
+	Success:        slog.LevelDebug,
+}
+
+// logEvent identifies the events logged by Client.Do.
+type logEvent int
+
+const (
+	eventAttemptStart logEvent = iota
+	eventAttemptResult
+	eventRetryScheduled
+	eventGiveUp
+	eventSuccess
+)
+
+var logEventMessages = map[logEvent]string{
+	eventAttemptStart:   "httpify: attempt start",
+	eventAttemptResult:  "httpify: attempt result",
+	eventRetryScheduled: "httpify: retry scheduled",
+	eventGiveUp:         "httpify: giving up",
+	eventSuccess:        "httpify: success",
+}
+
+func (l LogLevels) level(event logEvent) slog.Level {
+	switch event {
+	case eventAttemptStart:
+		return l.AttemptStart
+	case eventAttemptResult:
+		return l.AttemptResult
+	case eventRetryScheduled:
+		return l.RetryScheduled
+	case eventGiveUp:
+		return l.GiveUp
+	}
+	return l.Success
+}
+
+// log emits event for req through c.Logger, if any, with the method and URL
+// of req ahead of attrs.
+func (c *Client) log(ctx context.Context, event logEvent, req *Request, attrs ...slog.Attr) {
+	if c.Logger == nil {
+		return
+	}
+	levels := DefaultLogLevels
+	if c.LogLevels != nil {
+		levels = *c.LogLevels
+	}
+	level := levels.level(event)
+	if !c.Logger.Enabled(ctx, level) {
+		return
+	}
+	attrs = append([]slog.Attr{
+		slog.String(LogKeyMethod, req.Method),
+		slog.String(LogKeyURL, c.redactor().URL(req.URL)),
+	}, attrs...)
+	c.Logger.LogAttrs(ctx, level, logEventMessages[event], attrs...)
+}
+
+// logAttemptResult logs the outcome of attempt number n.
+func (c *Client) logAttemptResult(ctx context.Context, req *Request, n int, attempt *Attempt, resp *http.Response) {
+	if c.Logger == nil {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.Int(LogKeyAttempt, n),
+		slog.Duration(LogKeyDuration, attempt.Duration),
+		slog.String(LogKeyRemoteAddr, attempt.RemoteAddr),
+	}
+	if resp != nil {
+		attrs = append(attrs, slog.Int(LogKeyStatus, resp.StatusCode), slog.Any(LogKeyHeaders, logHeaders{resp.Header, c.redactor()}))
+	}
+	c.log(ctx, eventAttemptResult, req, append(attrs, errorAttrs(c.redactor().Error(attempt.Err))...)...)
+}
+
+// logRetryScheduled logs the wait before the attempt following number n and
+// the reason for retrying, which policies may leave nil.
+func (c *Client) logRetryScheduled(ctx context.Context, req *Request, n int, wait time.Duration, reason error) {
+	if c.Logger == nil {
+		return
+	}
+	attrs := []slog.Attr{slog.Int(LogKeyAttempt, n), slog.Duration(LogKeyWait, wait)}
+	if reason != nil {
+		attrs = append(attrs, slog.String(LogKeyReason, c.redactor().String(reason.Error())))
+	}
+	c.log(ctx, eventRetryScheduled, req, attrs...)
+}
+
+// errorAttrs describes err, if any.
+func errorAttrs(err error) []slog.Attr {
+	if err == nil {
+		return nil
+	}
+	return []slog.Attr{
+		slog.String(LogKeyError, err.Error()),
+		slog.String(LogKeyErrorKind, Classify(err).String()),
+	}
+}
+
+// logHeaders renders headers as a group once a record is actually logged,
+// redacted by redactor.
+type logHeaders struct {
+	header   http.Header
+	redactor *Redactor
+}
+
+func (h logHeaders) LogValue() slog.Value {
+	keys := make([]string, 0, len(h.header))
+	for key := range h.header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	attrs := make([]slog.Attr, 0, len(keys))
+	for _, key := range keys {
+		value := h.redactor.HeaderValue(key, strings.Join(h.header[key], ", "))
+		attrs = append(attrs, slog.String(key, value))
+	}
+	return slog.GroupValue(attrs...)
+}
@@ -0,0 +1,120 @@
+package httpify
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// decodeRecords parses the records written by a slog.JSONHandler.
+func decodeRecords(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestClientLogger(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Set-Cookie", "session=secret")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	client := NewClient(Options{RetryMax: 2, RetryWaitMin: time.Millisecond, RetryWaitMax: time.Millisecond, RespReadLimit: 4096})
+	client.Logger = slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	req, _ := NewRequest(http.MethodGet, server.URL, nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if !assert.Nil(t, err) {
+		return
+	}
+	resp.Body.Close()
+
+	records := decodeRecords(t, &buf)
+	var messages []string
+	for _, record := range records {
+		messages = append(messages, record["msg"].(string))
+		assert.Equal(t, http.MethodGet, record[LogKeyMethod])
+		assert.Equal(t, server.URL, record[LogKeyURL])
+	}
+	assert.Equal(t, []string{
+		"httpify: attempt start",
+		"httpify: attempt result",
+		"httpify: retry scheduled",
+		"httpify: attempt start",
+		"httpify: attempt result",
+		"httpify: success",
+	}, messages)
+	assert.NotContains(t, buf.String(), "secret")
+
+	start := records[0]
+	assert.Equal(t, "DEBUG", start["level"])
+	assert.Equal(t, map[string]any{"Authorization": "REDACTED", "Accept": "application/json"}, start[LogKeyHeaders])
+
+	result := records[1]
+	assert.EqualValues(t, http.StatusServiceUnavailable, result[LogKeyStatus])
+	assert.Equal(t, "REDACTED", result[LogKeyHeaders].(map[string]any)["Set-Cookie"])
+
+	retry := records[2]
+	assert.Equal(t, "INFO", retry["level"])
+	assert.EqualValues(t, 1, retry[LogKeyAttempt])
+	assert.EqualValues(t, time.Millisecond, retry[LogKeyWait])
+	assert.Contains(t, retry[LogKeyReason], "503")
+
+	success := records[5]
+	assert.EqualValues(t, 2, success[LogKeyAttempts])
+	assert.EqualValues(t, http.StatusOK, success[LogKeyStatus])
+}
+
+func TestClientLoggerGiveUp(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := server.URL
+	server.Close()
+
+	var buf bytes.Buffer
+	client := NewClient(Options{RetryMax: 0, Timeout: time.Second})
+	client.Logger = slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	client.LogLevels = &LogLevels{
+		AttemptStart:   slog.LevelDebug - 4,
+		AttemptResult:  slog.LevelDebug - 4,
+		RetryScheduled: slog.LevelInfo,
+		GiveUp:         slog.LevelError,
+		Success:        slog.LevelInfo,
+	}
+
+	req, _ := NewRequest(http.MethodGet, url, nil)
+	_, err := client.Do(req)
+	assert.NotNil(t, err)
+
+	records := decodeRecords(t, &buf)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, "httpify: giving up", records[0]["msg"])
+		assert.Equal(t, "ERROR", records[0]["level"])
+		assert.Equal(t, "conn_refused", records[0][LogKeyErrorKind])
+		assert.EqualValues(t, 1, records[0][LogKeyAttempts])
+	}
+}
@@ -0,0 +1,20 @@
+package httpify
+
+import "net/http"
+
+// DoFunc sends a request like Client.Do.
+type DoFunc func(req *Request) (*http.Response, error)
+
+// Middleware wraps a whole Client.Do call, retries included, e.g. to cache
+// responses or measure calls. It runs once per call and may return without
+// calling next.
+type Middleware func(next DoFunc) DoFunc
+
+// AttemptFunc sends a single attempt, like http.Client.Do.
+type AttemptFunc func(req *http.Request) (*http.Response, error)
+
+// AttemptMiddleware wraps every attempt made by Client.Do, e.g. to sign or
+// authenticate each attempt. AttemptFromContext gives access to the attempt
+// from the context of req. Like an http.RoundTripper, a middleware should not
+// modify req but send a copy made with req.Clone.
+type AttemptMiddleware func(next AttemptFunc) AttemptFunc
@@ -0,0 +1,91 @@
+package httpify
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientMiddlewareOrder(t *testing.T) {
+	var calls int32
+	var signatures []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signatures = append(signatures, r.Header.Get("X-Signature"))
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	var order []string
+	call := func(name string) Middleware {
+		return func(next DoFunc) DoFunc {
+			return func(req *Request) (*http.Response, error) {
+				order = append(order, name+" before")
+				resp, err := next(req)
+				order = append(order, name+" after")
+				return resp, err
+			}
+		}
+	}
+	sign := func(next AttemptFunc) AttemptFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			r, _, ok := AttemptFromContext(req.Context())
+			assert.True(t, ok)
+			order = append(order, "sign")
+			signed := req.Clone(req.Context())
+			signed.Header.Set("X-Signature", fmt.Sprintf("sig-%d", len(r.Metrics.Attempts)))
+			return next(signed)
+		}
+	}
+	count := func(next AttemptFunc) AttemptFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			order = append(order, "count")
+			return next(req)
+		}
+	}
+
+	var dump bytes.Buffer
+	client := NewClient(Options{RetryMax: 1, RetryWaitMin: time.Millisecond, RetryWaitMax: time.Millisecond, RespReadLimit: 4096})
+	client.Middlewares = []Middleware{call("outer"), call("inner")}
+	client.AttemptMiddlewares = []AttemptMiddleware{count, sign}
+	client.Dump = &DumpOptions{Writer: &dump}
+
+	req, _ := NewRequest(http.MethodGet, server.URL, nil)
+	resp, err := client.Do(req)
+	if assert.Nil(t, err) {
+		resp.Body.Close()
+	}
+
+	assert.Equal(t, []string{"outer before", "inner before", "count", "sign", "count", "sign", "inner after", "outer after"}, order)
+	assert.Equal(t, []string{"sig-1", "sig-2"}, signatures)
+	// The caller's request is left alone, the dump shows what was sent.
+	assert.Empty(t, req.Header.Get("X-Signature"))
+	assert.Equal(t, 2, strings.Count(dump.String(), "X-Signature: sig-"))
+}
+
+func TestClientMiddlewareShortCircuit(t *testing.T) {
+	cache := func(next DoFunc) DoFunc {
+		return func(req *Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("cached")), Request: req.Request}, nil
+		}
+	}
+
+	client := NewClient(Options{})
+	client.Middlewares = []Middleware{cache}
+	req, _ := NewRequest(http.MethodGet, "http://127.0.0.1:1", nil)
+	resp, err := client.Do(req)
+	if assert.Nil(t, err) {
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(t, "cached", string(body))
+	}
+	assert.Empty(t, req.Metrics.Attempts)
+}
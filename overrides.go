@@ -0,0 +1,78 @@
+package httpify
+
+import "time"
+
+// Overrides replaces client settings for a single request. Zero fields, and a
+// nil RetryMax, keep the value of the client.
+//
+// Settings are resolved on every Do call with the following precedence, the
+// first one set winning:
+//  1. Request.Overrides
+//  2. the Overrides of the first HostRule matching the request
+//  3. the Options, CheckRetry and RetryStrategy of the Client
+type Overrides struct {
+	// RetryMax is a pointer so that zero, i.e. no retries, can be requested.
+	RetryMax       *int
+	RetryWaitMin   time.Duration
+	RetryWaitMax   time.Duration
+	TotalTimeout   time.Duration
+	AttemptTimeout time.Duration
+	RespReadLimit  int64
+	CheckRetry     CheckRetry
+	RetryStrategy  RetryStrategy
+}
+
+// settings are the options a Do call runs with once overrides are applied.
+type settings struct {
+	Options
+	CheckRetry    CheckRetry
+	RetryStrategy RetryStrategy
+	// rule is the host rule matching the request, if any.
+	rule *hostRule
+}
+
+// settingsFor resolves the settings of req.
+func (c *Client) settingsFor(req *Request) settings {
+	s := settings{
+		Options:       c.options,
+		CheckRetry:    c.CheckRetry,
+		RetryStrategy: c.RetryStrategy,
+	}
+	if rule := c.HostRules.match(req.URL.Hostname()); rule != nil {
+		rule.Overrides.apply(&s)
+		s.rule = rule
+	}
+	req.Overrides.apply(&s)
+	return s
+}
+
+// apply copies the fields set in o to s. A nil o changes nothing.
+func (o *Overrides) apply(s *settings) {
+	if o == nil {
+		return
+	}
+	if o.RetryMax != nil {
+		s.RetryMax = *o.RetryMax
+	}
+	if o.RetryWaitMin != 0 {
+		s.RetryWaitMin = o.RetryWaitMin
+	}
+	if o.RetryWaitMax != 0 {
+		s.RetryWaitMax = o.RetryWaitMax
+	}
+	if o.TotalTimeout != 0 {
+		s.TotalTimeout = o.TotalTimeout
+	}
+	if o.AttemptTimeout != 0 {
+		s.AttemptTimeout = o.AttemptTimeout
+	}
+	if o.RespReadLimit != 0 {
+		s.RespReadLimit = o.RespReadLimit
+	}
+	if o.CheckRetry != nil {
+		s.CheckRetry = o.CheckRetry
+	}
+	if o.RetryStrategy != nil {
+		s.RetryStrategy = o.RetryStrategy
+	}
+}
@@ -0,0 +1,59 @@
+package httpify
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSettingsFor(t *testing.T) {
+	client := NewClient(Options{RetryMax: 5, RetryWaitMin: time.Second, RetryWaitMax: time.Minute, RespReadLimit: 4096})
+	req, _ := NewRequest(http.MethodGet, "http://example.com", nil)
+
+	s := client.settingsFor(req)
+	assert.Equal(t, 5, s.RetryMax)
+	assert.Equal(t, time.Second, s.RetryWaitMin)
+
+	retryMax := 0
+	req.Overrides = &Overrides{RetryMax: &retryMax, RetryWaitMax: 5 * time.Second, AttemptTimeout: time.Second}
+	s = client.settingsFor(req)
+	assert.Equal(t, 0, s.RetryMax)
+	assert.Equal(t, time.Second, s.RetryWaitMin, "unset fields keep the client value")
+	assert.Equal(t, 5*time.Second, s.RetryWaitMax)
+	assert.Equal(t, time.Second, s.AttemptTimeout)
+	assert.Equal(t, int64(4096), s.RespReadLimit)
+	assert.NotNil(t, s.CheckRetry)
+}
+
+func TestDoWithOverrides(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := NewClient(Options{RetryMax: 5, Timeout: 5 * time.Second, RespReadLimit: 4096})
+
+	retryMax := 1
+	req, _ := NewRequest(http.MethodGet, server.URL, nil)
+	req.Overrides = &Overrides{RetryMax: &retryMax}
+	_, err := client.Do(req)
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	atomic.StoreInt32(&calls, 0)
+	req, _ = NewRequest(http.MethodGet, server.URL, nil)
+	req.Overrides = &Overrides{CheckRetry: func(ctx context.Context, resp *http.Response, err error) (bool, error) {
+		return false, nil
+	}}
+	resp, err := client.Do(req)
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
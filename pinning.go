@@ -0,0 +1,133 @@
+package httpify
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// pinPrefix is the optional prefix of a pin, as written in HPKP headers.
+const pinPrefix = "sha256/"
+
+// PinMismatchError is returned by the TLS handshake when no certificate in the
+// chain presented by Host has a pinned public key.
+type PinMismatchError struct {
+	Host string
+	// Pins are the pins configured for the host.
+	Pins []string
+	// Got are the pins of the certificates in the chain, leaf first.
+	Got []string
+}
+
+func (e *PinMismatchError) Error() string {
+	return fmt.Sprintf("httpify: no pinned public key in the certificate chain of %s (got %s)",
+		e.Host, strings.Join(e.Got, ", "))
+}
+
+// SPKIPin returns the pin of cert: the base64 encoded SHA-256 hash of its
+// DER encoded SubjectPublicKeyInfo.
+func SPKIPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// validatePins checks that every pin is a base64 encoded SHA-256 hash.
+func validatePins(pins map[string][]string) error {
+	for host, hostPins := range pins {
+		if len(hostPins) == 0 {
+			return fmt.Errorf("pins for %s: empty pin set", host)
+		}
+		for _, pin := range hostPins {
+			if sum, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(pin, pinPrefix)); err != nil || len(sum) != sha256.Size {
+				return fmt.Errorf("pins for %s: %q is not a base64 encoded SHA-256 hash", host, pin)
+			}
+		}
+	}
+	return nil
+}
+
+// pinsFor returns the pins of host, compared case-insensitively. Hosts
+// without pins of their own use those of "*", if any.
+func pinsFor(pins map[string][]string, host string) []string {
+	for key, hostPins := range pins {
+		if strings.EqualFold(key, host) {
+			return hostPins
+		}
+	}
+	return pins["*"]
+}
+
+// verifyPins returns a tls.Config.VerifyConnection function checking that a
+// certificate of the chain has one of the pins of the server. The verified
+// chains are checked when certificates are verified and only the leaf
+// otherwise.
+func verifyPins(pins map[string][]string, serverName string) func(tls.ConnectionState) error {
+	return func(state tls.ConnectionState) error {
+		host := state.ServerName
+		if host == "" {
+			host = serverName
+		}
+		hostPins := pinsFor(pins, host)
+		if len(hostPins) == 0 {
+			return nil
+		}
+		if _, ok := matchPin(hostPins, &state); ok {
+			return nil
+		}
+
+		got := make([]string, len(state.PeerCertificates))
+		for i, cert := range state.PeerCertificates {
+			got[i] = SPKIPin(cert)
+		}
+		return &PinMismatchError{Host: host, Pins: hostPins, Got: got}
+	}
+}
+
+// matchPin returns the first of pins, as configured, that matches a
+// certificate in the verified chains of state. Without verification nothing
+// ties the other presented certificates to the leaf, anyone can send a
+// pinned intermediate along with their own leaf, so only the leaf is matched.
+func matchPin(pins []string, state *tls.ConnectionState) (string, bool) {
+	chains := state.VerifiedChains
+	if len(chains) == 0 && len(state.PeerCertificates) > 0 {
+		chains = [][]*x509.Certificate{state.PeerCertificates[:1]}
+	}
+	for _, chain := range chains {
+		for _, cert := range chain {
+			pin := SPKIPin(cert)
+			for _, want := range pins {
+				if pin == strings.TrimPrefix(want, pinPrefix) {
+					return want, true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+// pinnedKey returns the pin that matched the certificate chain of a response
+// from host, using the pins of the TLS options in effect for the request.
+// Chains verified against reloaded roots are not in state, the pin matched
+// during the handshake is used instead.
+func (c *Client) pinnedKey(s settings, host string, state *tls.ConnectionState) string {
+	options, reloader := &c.options.TLS, c.reloader
+	if s.rule != nil {
+		switch {
+		case s.rule.TLS != nil:
+			options, reloader = s.rule.TLS, s.rule.reloader
+		case s.rule.TLSConfig != nil:
+			return ""
+		}
+	}
+	if reloader != nil && reloader.verifies() {
+		return reloader.pinnedKey(state)
+	}
+	if options.ServerName != "" {
+		host = options.ServerName
+	}
+	pin, _ := matchPin(pinsFor(options.Pins, host), state)
+	return pin
+}
@@ -0,0 +1,148 @@
+package httpify
+
+import (
+	"crypto/tls"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTLSOptionsPinsValidation(t *testing.T) {
+	tests := []struct {
+		name  string
+		pins  map[string][]string
+		valid bool
+	}{
+		{"Plain", map[string][]string{"example.com": {strings.Repeat("A", 43) + "="}}, true},
+		{"Prefixed", map[string][]string{"example.com": {"sha256/" + strings.Repeat("A", 43) + "="}}, true},
+		{"Not base64", map[string][]string{"example.com": {"not a pin"}}, false},
+		{"Wrong length", map[string][]string{"example.com": {"AAAA"}}, false},
+		{"Empty set", map[string][]string{"example.com": {}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := TLSOptions{Pins: tt.pins}.Config()
+			assert.Equal(t, tt.valid, err == nil, err)
+		})
+	}
+}
+
+func TestClientPinning(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCert(t, dir, "server")
+	server := newTLSTestServer(t, certFile, keyFile, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverPin := SPKIPin(cert.Leaf)
+	otherPin := strings.Repeat("A", 43) + "="
+
+	tests := []struct {
+		name     string
+		pins     map[string][]string
+		expected string
+		kind     ErrorKind
+	}{
+		{"Matching pin", map[string][]string{"localhost": {serverPin}}, serverPin, KindNone},
+		{"Backup pin", map[string][]string{"LOCALHOST": {otherPin, "sha256/" + serverPin}}, "sha256/" + serverPin, KindNone},
+		{"Wildcard", map[string][]string{"*": {serverPin}}, serverPin, KindNone},
+		{"Other host", map[string][]string{"example.com": {otherPin}}, "", KindNone},
+		{"Mismatch", map[string][]string{"localhost": {otherPin}}, "", KindTLSPinMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewClient(Options{RetryMax: 2, Timeout: 5 * time.Second, TLS: TLSOptions{RootCAFiles: []string{certFile}, Pins: tt.pins}})
+			req, _ := NewRequest(http.MethodGet, url, nil)
+			resp, err := client.Do(req)
+			if resp != nil {
+				resp.Body.Close()
+			}
+
+			assert.Equal(t, tt.kind, Classify(err))
+			// A pin mismatch is not retried.
+			assert.Len(t, req.Metrics.Attempts, 1)
+			assert.Equal(t, tt.expected, req.Metrics.Attempts[0].PinnedKey)
+			if tt.kind == KindTLSPinMismatch {
+				var pinErr *PinMismatchError
+				if assert.ErrorAs(t, err, &pinErr) {
+					assert.Equal(t, "localhost", pinErr.Host)
+					assert.Equal(t, []string{serverPin}, pinErr.Got)
+				}
+			}
+		})
+	}
+}
+
+func TestClientPinningReloadedRoots(t *testing.T) {
+	dir := t.TempDir()
+	caFile, caKeyFile := writeTestCert(t, dir, "ca")
+	certFile, keyFile := issueTestCert(t, dir, "server", caFile, caKeyFile)
+	server := newTLSTestServer(t, certFile, keyFile, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	ca, err := tls.LoadX509KeyPair(caFile, caKeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caPin := SPKIPin(ca.Leaf)
+
+	client := NewClient(Options{Timeout: 5 * time.Second, TLS: TLSOptions{
+		RootCAFiles:    []string{caFile},
+		ReloadInterval: time.Second,
+		Pins:           map[string][]string{"localhost": {strings.Repeat("A", 43) + "=", caPin}},
+	}})
+	// The second request reuses the connection of the first.
+	for i := 0; i < 2; i++ {
+		req, _ := NewRequest(http.MethodGet, strings.Replace(server.URL, "127.0.0.1", "localhost", 1), nil)
+		resp, err := client.Do(req)
+		if assert.NoError(t, err) {
+			resp.Body.Close()
+		}
+		assert.Equal(t, caPin, req.Metrics.Attempts[0].PinnedKey)
+	}
+}
+
+func TestClientPinningWithoutVerification(t *testing.T) {
+	dir := t.TempDir()
+	pinnedFile, pinnedKeyFile := writeTestCert(t, dir, "pinned")
+	forgedFile, forgedKeyFile := writeTestCert(t, dir, "forged")
+	pinned, err := tls.LoadX509KeyPair(pinnedFile, pinnedKeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The forged leaf comes with the pinned certificate as its intermediate.
+	server := newTLSTestServer(t, forgedFile, forgedKeyFile, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), func(cfg *tls.Config) {
+		cfg.Certificates[0].Certificate = append(cfg.Certificates[0].Certificate, pinned.Certificate[0])
+	})
+	url := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+
+	tests := []struct {
+		name string
+		pin  string
+		kind ErrorKind
+	}{
+		{"Leaf", SPKIPin(server.TLS.Certificates[0].Leaf), KindNone},
+		{"Unverified intermediate", SPKIPin(pinned.Leaf), KindTLSPinMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewClient(Options{Timeout: 5 * time.Second, TLS: TLSOptions{
+				InsecureSkipVerify: true,
+				Pins:               map[string][]string{"localhost": {tt.pin}},
+			}})
+			req, _ := NewRequest(http.MethodGet, url, nil)
+			resp, err := client.Do(req)
+			if resp != nil {
+				resp.Body.Close()
+			}
+			assert.Equal(t, tt.kind, Classify(err))
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package httpify
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces secrets removed by a Redactor.
+const Redacted = "REDACTED"
+
+// Redactor removes secrets from what the client logs, traces, dumps and puts
+// in error messages. Its fields may be extended, e.g.
+//
+//	r := httpify.DefaultRedactor()
+//	r.Headers = append(r.Headers, "X-Tenant-Secret")
+//	r.Patterns = append(r.Patterns, regexp.MustCompile(`sk_live_\w+`))
+//
+// A nil *Redactor redacts nothing.
+type Redactor struct {
+	// Headers are the names of headers whose values are redacted, compared
+	// case-insensitively.
+	Headers []string
+	// QueryParams are the names of query parameters whose values are
+	// redacted, compared case-insensitively.
+	QueryParams []string
+	// Patterns match secrets anywhere in text, which are replaced as a whole.
+	Patterns []*regexp.Regexp
+}
+
+// DefaultRedactor returns a Redactor for common credential headers, query
+// parameters, bearer tokens, JWTs and AWS access key IDs. The password of
+// URLs is always redacted.
+func DefaultRedactor() *Redactor {
+	return &Redactor{
+		Headers: []string{
+			"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie",
+			"X-Api-Key", "X-Auth-Token", "X-Access-Token", "X-Csrf-Token",
+			"X-Amz-Security-Token",
+		},
+		QueryParams: []string{
+			"access_token", "refresh_token", "id_token", "token", "api_key",
+			"apikey", "key", "client_secret", "password", "secret", "signature",
+			"sig", "x-amz-signature", "x-amz-credential", "x-amz-security-token",
+		},
+		Patterns: []*regexp.Regexp{
+			regexp.MustCompile(`(?i)\bbearer\s+[a-z0-9\-._~+/]+=*`),
+			regexp.MustCompile(`\beyJ[\w-]+\.[\w-]+\.[\w-]+`),
+			regexp.MustCompile(`\bAKIA[0-9A-Z]{16}\b`),
+		},
+	}
+}
+
+// sensitiveHeader reports whether the values of the header name are redacted.
+func (r *Redactor) sensitiveHeader(name string) bool {
+	for _, header := range r.Headers {
+		if strings.EqualFold(header, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// sensitiveParam reports whether the values of the query parameter name are
+// redacted.
+func (r *Redactor) sensitiveParam(name string) bool {
+	for _, param := range r.QueryParams {
+		if strings.EqualFold(param, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Header returns a copy of h with the values of sensitive headers redacted
+// and patterns removed from the others.
+func (r *Redactor) Header(h http.Header) http.Header {
+	if r == nil || h == nil {
+		return h
+	}
+	out := make(http.Header, len(h))
+	for name, values := range h {
+		redacted := make([]string, len(values))
+		for i, value := range values {
+			redacted[i] = r.HeaderValue(name, value)
+		}
+		out[name] = redacted
+	}
+	return out
+}
+
+// HeaderValue returns value, a value of the header name, redacted.
+func (r *Redactor) HeaderValue(name, value string) string {
+	if r == nil {
+		return value
+	}
+	if r.sensitiveHeader(name) {
+		return Redacted
+	}
+	return r.String(value)
+}
+
+// URL returns u as a string with its password, sensitive query parameters
+// and patterns redacted.
+func (r *Redactor) URL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	if r == nil {
+		return u.String()
+	}
+	redacted := *u
+	if _, ok := u.User.Password(); ok {
+		redacted.User = url.UserPassword(u.User.Username(), Redacted)
+	}
+	if u.RawQuery != "" {
+		redacted.RawQuery = r.query(u.RawQuery)
+	}
+	return r.String(redacted.String())
+}
+
+// query redacts the sensitive parameters of a raw query, keeping the order
+// and encoding of the others.
+func (r *Redactor) query(raw string) string {
+	pairs := strings.Split(raw, "&")
+	for i, pair := range pairs {
+		rawName, _, found := strings.Cut(pair, "=")
+		name := rawName
+		if unescaped, err := url.QueryUnescape(rawName); err == nil {
+			name = unescaped
+		}
+		if found && r.sensitiveParam(name) {
+			pairs[i] = rawName + "=" + Redacted
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// queryInText matches query parameters inside free text, such as the URL
+// quoted in the message of a *url.Error.
+var queryInText = regexp.MustCompile(`([?&])([^=&\s"'#]+)=([^&\s"'#]*)`)
+
+// userinfoInText matches the password of URLs inside free text.
+var userinfoInText = regexp.MustCompile(`(://[^:/@\s"']*):[^@/\s"']*@`)
+
+// String redacts the secrets found in s: passwords and sensitive query
+// parameters of the URLs it contains, and every pattern.
+func (r *Redactor) String(s string) string {
+	if r == nil {
+		return s
+	}
+	s = userinfoInText.ReplaceAllString(s, "$1:"+Redacted+"@")
+	s = queryInText.ReplaceAllStringFunc(s, func(match string) string {
+		groups := queryInText.FindStringSubmatch(match)
+		name := groups[2]
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if !r.sensitiveParam(name) {
+			return match
+		}
+		return groups[1] + groups[2] + "=" + Redacted
+	})
+	for _, pattern := range r.Patterns {
+		s = pattern.ReplaceAllString(s, Redacted)
+	}
+	return s
+}
+
+// redactedError hides secrets in the message of an error while keeping it
+// available to errors.Is and errors.As.
+type redactedError struct {
+	err      error
+	redactor *Redactor
+}
+
+func (e *redactedError) Error() string {
+	return e.redactor.String(e.err.Error())
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// Error returns err with its message redacted, nil for a nil err.
+func (r *Redactor) Error(err error) error {
+	if r == nil || err == nil {
+		return err
+	}
+	return &redactedError{err, r}
+}
+
+// redactor returns the redactor of the client, DefaultRedactor when nil.
+func (c *Client) redactor() *Redactor {
+	if c.Redactor != nil {
+		return c.Redactor
+	}
+	return defaultRedactor
+}
+
+var defaultRedactor = DefaultRedactor()
@@ -3,10 +3,9 @@ package httpify
 import (
 	"bytes"
 	"context"
-	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
-	"net/http/httptrace"
 	"os"
 )
 
@@ -20,6 +19,11 @@ type Request struct {
 	body ReaderFunc
 	*http.Request
 	Metrics Metrics
+	// Overrides, when set, replaces client settings for this request.
+	Overrides *Overrides
+	// TraceSink, when set, receives the connection trace of every attempt
+	// instead of Client.TraceSink.
+	TraceSink TraceSink
 }
 
 // Metrics stores retry and error metrics for a request.
@@ -27,12 +31,27 @@ type Metrics struct {
 	Failures    int
 	Retries     int
 	DrainErrors int
+	// Hedges is the number of duplicate requests sent by Client.DoHedged.
+	Hedges int
+	// Attempts holds one record per attempt made by Client.Do.
+	Attempts []Attempt
 }
 
-// RequestLogHook allows executing custom logic before each retry.
+// merge adds the metrics of a copy of the request to m.
+func (m *Metrics) merge(other Metrics) {
+	m.Failures += other.Failures
+	m.Retries += other.Retries
+	m.DrainErrors += other.DrainErrors
+	m.Hedges += other.Hedges
+	m.Attempts = append(m.Attempts, other.Attempts...)
+}
+
+// RequestLogHook allows executing custom logic before each retry. The request
+// is passed as sent, pass it through a Redactor before logging it.
 type RequestLogHook func(*http.Request, int)
 
-// ResponseLogHook allows executing custom logic after each HTTP request.
+// ResponseLogHook allows executing custom logic after each HTTP request. The
+// response is passed as received, see RequestLogHook.
 type ResponseLogHook func(*http.Response)
 
 // ErrorHandler handles retries exhaustion and returns custom responses.
@@ -54,7 +73,7 @@ func NewRequest(method, url string, body interface{}) (*Request, error) {
 	}
 	httpReq.ContentLength = contentLength
 
-	return &Request{bodyReader, httpReq, Metrics{}}, nil
+	return &Request{body: bodyReader, Request: httpReq}, nil
 }
 
 // NewRequestWithContext creates a new wrapped request with a context.
@@ -70,7 +89,7 @@ func NewRequestWithContext(ctx context.Context, method, url string, body interfa
 	}
 	httpReq.ContentLength = contentLength
 
-	return &Request{bodyReader, httpReq, Metrics{}}, nil
+	return &Request{body: bodyReader, Request: httpReq}, nil
 }
 
 // WithContext returns a shallow copy of the request with a new context.
@@ -79,6 +98,16 @@ func (r *Request) WithContext(ctx context.Context) *Request {
 	return r
 }
 
+// clone returns a copy of the request bound to ctx with fresh metrics.
+func (r *Request) clone(ctx context.Context) *Request {
+	return &Request{
+		body:      r.body,
+		Request:   r.Request.Clone(ctx),
+		Overrides: r.Overrides,
+		TraceSink: r.TraceSink,
+	}
+}
+
 // FromRequest wraps an http.Request into a retryable Request.
 func FromRequest(r *http.Request) (*Request, error) {
 	bodyReader, contentLength, err := getBodyReaderAndContentLength(r.Body)
@@ -98,31 +127,17 @@ func FromRequest(r *http.Request) (*Request, error) {
 }
 
 
-// FromRequestWithTrace wraps an http.Request into a retryable Request with trace enabled.
+// FromRequestWithTrace wraps an http.Request into a retryable Request whose
+// attempts are traced to os.Stderr.
+//
+// Deprecated: set Request.TraceSink or Client.TraceSink instead.
 func FromRequestWithTrace(r *http.Request) (*Request, error) {
-	trace := &httptrace.ClientTrace{
-		GotConn: func(connInfo httptrace.GotConnInfo) {
-			fmt.Fprintf(os.Stderr, "Got connection (Reused: %v, Idle: %v, IdleTime: %v)\n", connInfo.Reused, connInfo.WasIdle, connInfo.IdleTime)
-		},
-		ConnectStart: func(network, addr string) {
-			fmt.Fprintf(os.Stderr, "Connecting (network: %s, address: %s)\n", network, addr)
-		},
-		ConnectDone: func(network, addr string, err error) {
-			fmt.Fprintf(os.Stderr, "Connected (network: %s, address: %s, error: %v)\n", network, addr, err)
-		},
-		GotFirstResponseByte: func() {
-			fmt.Fprintf(os.Stderr, "Received first byte of response\n")
-		},
-		WroteHeaders: func() {
-			fmt.Fprintf(os.Stderr, "Request headers written\n")
-		},
-		WroteRequest: func(wr httptrace.WroteRequestInfo) {
-			fmt.Fprintf(os.Stderr, "Request sent (error: %v)\n", wr.Err)
-		},
+	req, err := FromRequest(r)
+	if err != nil {
+		return nil, err
 	}
-
-	r = r.WithContext(httptrace.WithClientTrace(r.Context(), trace))
-	return FromRequest(r)
+	req.TraceSink = SlogTraceSink(slog.New(slog.NewTextHandler(os.Stderr, nil)), slog.LevelInfo)
+	return req, nil
 }
 
 // BodyBytes returns a copy of the request body data.
@@ -2,7 +2,7 @@ package httpify
 
 import (
 	"context"
-	"crypto/x509"
+	"fmt"
 	"net/http"
 	"net/url"
 	"regexp"
@@ -24,11 +24,8 @@ func DefaultRetryPolicy() CheckRetry {
 		}
 
 		if err != nil {
-			if urlErr, ok := err.(*url.Error); ok {
-				// Handle specific error conditions
-				if isNonRetryableError(urlErr) {
-					return false, nil
-				}
+			if isNonRetryableKind(Classify(err)) {
+				return false, nil
 			}
 			return true, nil // Retry on likely recoverable error
 		}
@@ -37,18 +34,93 @@ func DefaultRetryPolicy() CheckRetry {
 	}
 }
 
+// StatusMatcher reports whether a response status code should be retried.
+type StatusMatcher func(code int) bool
+
+// DefaultRetryableStatus retries request timeouts (408), rate limiting (429)
+// and every server error except 501 Not Implemented.
+func DefaultRetryableStatus(code int) bool {
+	switch code {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return true
+	case http.StatusNotImplemented:
+		return false
+	}
+	return code >= 500 && code <= 599
+}
+
+// StatusCodes returns a StatusMatcher accepting exactly the given codes.
+func StatusCodes(codes ...int) StatusMatcher {
+	return func(code int) bool {
+		for _, c := range codes {
+			if c == code {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// StatusClass returns a StatusMatcher accepting a whole class of codes,
+// e.g. StatusClass(5) for 5xx.
+func StatusClass(class int) StatusMatcher {
+	return func(code int) bool {
+		return code/100 == class
+	}
+}
+
+// StatusRetryPolicy retries on connection errors like DefaultRetryPolicy and on
+// responses whose status code is accepted by any of the matchers, falling back
+// to DefaultRetryableStatus when none are given. Every other response,
+// including 4xx, is terminal. When a response is retried the returned error
+// names the status code, so it is reported once retries are exhausted.
+func StatusRetryPolicy(matchers ...StatusMatcher) CheckRetry {
+	if len(matchers) == 0 {
+		matchers = []StatusMatcher{DefaultRetryableStatus}
+	}
+	connPolicy := DefaultRetryPolicy()
+	return func(ctx context.Context, resp *http.Response, err error) (bool, error) {
+		if err != nil || resp == nil {
+			return connPolicy(ctx, resp, err)
+		}
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+
+		for _, match := range matchers {
+			if match(resp.StatusCode) {
+				return true, retryableStatusError(resp)
+			}
+		}
+		return false, nil
+	}
+}
+
+// retryableStatusError describes why resp is retried.
+func retryableStatusError(resp *http.Response) error {
+	return fmt.Errorf("retryable status code %d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+}
+
 // HostSprayRetryPolicy retries on connection and server errors for host-spraying use cases.
 func HostSprayRetryPolicy() CheckRetry {
 	return DefaultRetryPolicy()
 }
 
 func isNonRetryableError(urlErr *url.Error) bool {
-	return redirectsErrorRegex.MatchString(urlErr.Error()) ||
-		schemeErrorRegex.MatchString(urlErr.Error()) ||
-		isTLSCertError(urlErr)
+	return isNonRetryableKind(Classify(urlErr))
+}
+
+// isNonRetryableKind reports whether errors of the given kind will not go
+// away by retrying: redirect loops, unsupported schemes, certificates that
+// fail verification or pinning and hosts that do not exist.
+func isNonRetryableKind(kind ErrorKind) bool {
+	switch kind {
+	case KindRedirect, KindScheme, KindDNSNotFound:
+		return true
+	}
+	return kind.IsTLSCert()
 }
 
 func isTLSCertError(urlErr *url.Error) bool {
-	_, ok := urlErr.Err.(x509.UnknownAuthorityError)
-	return ok
+	return Classify(urlErr).IsTLSCert()
 }
@@ -0,0 +1,156 @@
+package httpify
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+// bodyPeekLimit is the number of response body bytes OnBodyMatch looks at.
+const bodyPeekLimit = 64 << 10
+
+// The functions below build CheckRetry policies out of smaller ones.
+// Conditions such as OnStatus or OnMethods report whether they match and are
+// meant to be combined with AnyOf, AllOf and Not, e.g.
+//
+//	AnyOf(
+//		AllOf(OnMethods(http.MethodGet), OnStatus(http.StatusBadGateway)),
+//		OnErrorKind(KindConnRefused, KindTimeout),
+//	)
+//
+// Combinators never retry once the context is done.
+
+// AnyOf retries when at least one of the policies does.
+func AnyOf(policies ...CheckRetry) CheckRetry {
+	return func(ctx context.Context, resp *http.Response, err error) (bool, error) {
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		var firstErr error
+		for _, policy := range policies {
+			retry, checkErr := policy(ctx, resp, err)
+			if retry {
+				return true, checkErr
+			}
+			if firstErr == nil {
+				firstErr = checkErr
+			}
+		}
+		return false, firstErr
+	}
+}
+
+// AllOf retries when every one of the policies does.
+func AllOf(policies ...CheckRetry) CheckRetry {
+	return func(ctx context.Context, resp *http.Response, err error) (bool, error) {
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		var reason error
+		for _, policy := range policies {
+			retry, checkErr := policy(ctx, resp, err)
+			if !retry {
+				return false, checkErr
+			}
+			if checkErr != nil {
+				reason = checkErr
+			}
+		}
+		return len(policies) > 0, reason
+	}
+}
+
+// Not retries when policy does not.
+func Not(policy CheckRetry) CheckRetry {
+	return func(ctx context.Context, resp *http.Response, err error) (bool, error) {
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		retry, _ := policy(ctx, resp, err)
+		return !retry, nil
+	}
+}
+
+// OnStatus matches responses with one of the given status codes.
+func OnStatus(codes ...int) CheckRetry {
+	match := StatusCodes(codes...)
+	return func(ctx context.Context, resp *http.Response, err error) (bool, error) {
+		if resp != nil && match(resp.StatusCode) {
+			return true, retryableStatusError(resp)
+		}
+		return false, nil
+	}
+}
+
+// OnMethods matches requests sent with one of the given methods.
+func OnMethods(methods ...string) CheckRetry {
+	return func(ctx context.Context, resp *http.Response, err error) (bool, error) {
+		req, _, ok := AttemptFromContext(ctx)
+		if !ok {
+			return false, nil
+		}
+		for _, method := range methods {
+			if req.Method == method {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+}
+
+// OnErrorKind matches attempts failing with an error of one of the given kinds.
+func OnErrorKind(kinds ...ErrorKind) CheckRetry {
+	return func(ctx context.Context, resp *http.Response, err error) (bool, error) {
+		if err == nil {
+			return false, nil
+		}
+		kind := Classify(err)
+		for _, k := range kinds {
+			if k == kind {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+}
+
+// MaxElapsed matches as long as less than d has passed since the first
+// attempt of the current Do call.
+func MaxElapsed(d time.Duration) CheckRetry {
+	return func(ctx context.Context, resp *http.Response, err error) (bool, error) {
+		elapsed, ok := elapsedFromContext(ctx)
+		if !ok {
+			return true, nil
+		}
+		return elapsed < d, nil
+	}
+}
+
+// OnBodyMatch matches responses whose body matches re. Only the first 64KiB
+// of the body are looked at, and they are put back so the caller still reads
+// the complete body.
+func OnBodyMatch(re *regexp.Regexp) CheckRetry {
+	return func(ctx context.Context, resp *http.Response, err error) (bool, error) {
+		if resp == nil || resp.Body == nil {
+			return false, nil
+		}
+		peeked, readErr := io.ReadAll(io.LimitReader(resp.Body, bodyPeekLimit))
+		resp.Body = &peekedBody{
+			Reader: io.MultiReader(bytes.NewReader(peeked), resp.Body),
+			Closer: resp.Body,
+		}
+		if readErr != nil {
+			return false, nil
+		}
+		return re.Match(peeked), nil
+	}
+}
+
+// peekedBody replays the bytes read from a body before the rest of it.
+type peekedBody struct {
+	io.Reader
+	io.Closer
+}
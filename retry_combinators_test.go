@@ -0,0 +1,85 @@
+package httpify
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryCombinators(t *testing.T) {
+	getReq, _ := NewRequest(http.MethodGet, "http://example.com", nil)
+	postReq, _ := NewRequest(http.MethodPost, "http://example.com", nil)
+	getCtx := withAttempt(context.Background(), getReq, &Attempt{}, time.Now(), SystemClock)
+	postCtx := withAttempt(context.Background(), postReq, &Attempt{}, time.Now(), SystemClock)
+	oldCtx := withAttempt(context.Background(), getReq, &Attempt{}, time.Now().Add(-time.Hour), SystemClock)
+
+	badGateway := &http.Response{StatusCode: http.StatusBadGateway}
+	refused := &url.Error{Op: "Get", URL: "http://example.com", Err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}}
+
+	policy := AnyOf(
+		AllOf(OnMethods(http.MethodGet), OnStatus(http.StatusBadGateway), MaxElapsed(time.Minute)),
+		OnErrorKind(KindConnRefused),
+	)
+
+	tests := []struct {
+		name     string
+		policy   CheckRetry
+		ctx      context.Context
+		resp     *http.Response
+		err      error
+		expected bool
+	}{
+		{"GET on 502", policy, getCtx, badGateway, nil, true},
+		{"POST on 502", policy, postCtx, badGateway, nil, false},
+		{"GET on 502 too late", policy, oldCtx, badGateway, nil, false},
+		{"Connection error", policy, postCtx, nil, refused, true},
+		{"GET on 200", policy, getCtx, &http.Response{StatusCode: http.StatusOK}, nil, false},
+		{"Not", Not(OnStatus(http.StatusOK)), getCtx, badGateway, nil, true},
+		{"Empty AllOf", AllOf(), getCtx, badGateway, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			retry, _ := tt.policy(tt.ctx, tt.resp, tt.err)
+			assert.Equal(t, tt.expected, retry)
+		})
+	}
+}
+
+func TestRetryCombinatorsCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, policy := range []CheckRetry{AnyOf(OnStatus(502)), AllOf(OnStatus(502)), Not(OnStatus(200))} {
+		retry, err := policy(ctx, &http.Response{StatusCode: 502}, nil)
+		assert.False(t, retry)
+		assert.True(t, errors.Is(err, context.Canceled))
+	}
+}
+
+func TestOnBodyMatch(t *testing.T) {
+	policy := OnBodyMatch(regexp.MustCompile(`temporarily unavailable`))
+	resp := &http.Response{Body: io.NopCloser(strings.NewReader("service temporarily unavailable, retry"))}
+
+	retry, _ := policy(context.Background(), resp, nil)
+	assert.True(t, retry)
+
+	// The body is still complete for the caller.
+	body, err := io.ReadAll(resp.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, "service temporarily unavailable, retry", string(body))
+
+	resp = &http.Response{Body: io.NopCloser(strings.NewReader("all good"))}
+	retry, _ = policy(context.Background(), resp, nil)
+	assert.False(t, retry)
+}
@@ -4,16 +4,124 @@ import (
 	"math"
 	"math/rand"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// unixTimestampThreshold separates rate-limit reset values sent as absolute
+// Unix timestamps from those sent as delta seconds.
+const unixTimestampThreshold = 1e9
+
 // RetryStrategy defines how long to wait between retries.
 type RetryStrategy func(min, max time.Duration, attemptNum int, resp *http.Response) time.Duration
 
+// StrategyOption configures the randomness and clock of a RetryStrategy.
+type StrategyOption func(*strategyConfig)
+
+type strategyConfig struct {
+	rand  *rand.Rand
+	clock Clock
+}
+
+// WithRand makes a strategy draw from r, which must be safe for concurrent
+// use, see NewRand.
+func WithRand(r *rand.Rand) StrategyOption {
+	return func(cfg *strategyConfig) {
+		cfg.rand = r
+	}
+}
+
+// WithSeed makes a strategy draw from a source seeded with seed.
+func WithSeed(seed int64) StrategyOption {
+	return WithRand(NewRand(seed))
+}
+
+// WithClock makes a strategy tell the time with clock.
+func WithClock(clock Clock) StrategyOption {
+	return func(cfg *strategyConfig) {
+		cfg.clock = clock
+	}
+}
+
+func newStrategyConfig(opts []StrategyOption) strategyConfig {
+	var cfg strategyConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.rand == nil {
+		cfg.rand = newRandSource()
+	}
+	cfg.clock = clockOrSystem(cfg.clock)
+	return cfg
+}
+
 // DefaultRetryStrategy implements exponential retryStrategy based on attempt count, bounded by min and max durations.
-func DefaultRetryStrategy() RetryStrategy {
+// It is deterministic, so options are accepted for uniformity only.
+func DefaultRetryStrategy(opts ...StrategyOption) RetryStrategy {
+	return BoundedRetryStrategy(func(min, max time.Duration, attemptNum int, resp *http.Response) time.Duration {
+		return scaledCeiling(min, max, math.Pow(2, float64(attemptNum)))
+	})
+}
+
+// LinearRandomizedRetryStrategy waits a random duration between min and a
+// ceiling growing linearly with the attempt count, from twice min on the
+// first retry.
+func LinearRandomizedRetryStrategy(opts ...StrategyOption) RetryStrategy {
+	randSource := newStrategyConfig(opts).rand
+	return BoundedRetryStrategy(func(min, max time.Duration, attemptNum int, resp *http.Response) time.Duration {
+		return randomBetween(randSource, min, scaledCeiling(min, max, float64(attemptNum+2)))
+	})
+}
+
+// RandomizedFullRetryStrategy implements capped exponential retryStrategy with full randomized:
+// a random duration between min and an exponentially growing ceiling, from
+// twice min on the first retry.
+func RandomizedFullRetryStrategy(opts ...StrategyOption) RetryStrategy {
+	randSource := newStrategyConfig(opts).rand
+	return BoundedRetryStrategy(func(min, max time.Duration, attemptNum int, resp *http.Response) time.Duration {
+		return randomBetween(randSource, min, scaledCeiling(min, max, math.Pow(2, float64(attemptNum+1))))
+	})
+}
+
+// ExponentialRandomizedRetryStrategy adds randomized to exponential retryStrategy.
+func ExponentialRandomizedRetryStrategy(opts ...StrategyOption) RetryStrategy {
+	randSource := newStrategyConfig(opts).rand
+	return BoundedRetryStrategy(func(min, max time.Duration, attemptNum int, resp *http.Response) time.Duration {
+		base := scaledCeiling(min, max, math.Pow(2, float64(attemptNum)))
+		return randomBetween(randSource, base, scaledCeiling(base, max, 2))
+	})
+}
+
+// EqualJitterRetryStrategy implements the "equal jitter" backoff: half of an
+// exponentially growing ceiling is always waited and the other half is random.
+func EqualJitterRetryStrategy(opts ...StrategyOption) RetryStrategy {
+	randSource := newStrategyConfig(opts).rand
+	return BoundedRetryStrategy(func(min, max time.Duration, attemptNum int, resp *http.Response) time.Duration {
+		half := scaledCeiling(min, max, math.Pow(2, float64(attemptNum+1))) / 2
+		return randomBetween(randSource, half, 2*half)
+	})
+}
+
+// BoundedRetryStrategy validates the input and output of strategy: a
+// negative min or attempt count is treated as zero, a max below min as min,
+// and the returned duration is clamped to [min, max].
+func BoundedRetryStrategy(strategy RetryStrategy) RetryStrategy {
 	return func(min, max time.Duration, attemptNum int, resp *http.Response) time.Duration {
-		sleep := time.Duration(math.Pow(2, float64(attemptNum)) * float64(min))
+		if min < 0 {
+			min = 0
+		}
+		if max < min {
+			max = min
+		}
+		if attemptNum < 0 {
+			attemptNum = 0
+		}
+
+		sleep := strategy(min, max, attemptNum, resp)
+		if sleep < min {
+			return min
+		}
 		if sleep > max {
 			return max
 		}
@@ -21,60 +129,97 @@ func DefaultRetryStrategy() RetryStrategy {
 	}
 }
 
-// jitterRetryStrategy returns a Randomized duration within the range of min to max.
-func jitterRetryStrategy(min, max time.Duration, attemptNum int, randSource *rand.Rand) time.Duration {
-	randomized := randSource.Float64() * float64(max-min)
-	jitterDuration := time.Duration(int64(randomized) + int64(min))
-	return jitterDuration * time.Duration(attemptNum+1)
+// scaledCeiling returns d multiplied by factor, capped at max without
+// overflowing.
+func scaledCeiling(d, max time.Duration, factor float64) time.Duration {
+	scaled := float64(d) * factor
+	if scaled >= float64(max) {
+		return max
+	}
+	return time.Duration(scaled)
+}
+
+// randomBetween returns a random duration in [lo, hi].
+func randomBetween(randSource *rand.Rand, lo, hi time.Duration) time.Duration {
+	if hi <= lo {
+		return lo
+	}
+	return lo + time.Duration(randSource.Int63n(int64(hi-lo)+1))
+}
+
+// RetryAfterRetryStrategy waits as long as the server asks through the
+// Retry-After or rate-limit reset headers and otherwise behaves like
+// DefaultRetryStrategy.
+func RetryAfterRetryStrategy(opts ...StrategyOption) RetryStrategy {
+	return WithRetryAfter(DefaultRetryStrategy(), opts...)
 }
 
-// LinearRandomizedRetryStrategy adds random randomized to linear retryStrategy.
-func LinearRandomizedRetryStrategy() RetryStrategy {
-	randSource := newRandSource()
+// WithRetryAfter decorates strategy so that a wait requested by the server
+// takes precedence. The requested wait is clamped to max; when the response
+// carries no such header the wrapped strategy decides.
+func WithRetryAfter(strategy RetryStrategy, opts ...StrategyOption) RetryStrategy {
+	clock := newStrategyConfig(opts).clock
 	return func(min, max time.Duration, attemptNum int, resp *http.Response) time.Duration {
-		if max <= min {
-			return min * time.Duration(attemptNum+1)
+		if wait, ok := retryAfter(resp, clock.Now()); ok {
+			if wait > max {
+				return max
+			}
+			return wait
 		}
+		return strategy(min, max, attemptNum, resp)
+	}
+}
 
-		// Calculate Randomized retryStrategy
-		RandomizedDuration := jitterRetryStrategy(min, max, attemptNum, randSource)
+// retryAfter extracts the wait requested by resp. Retry-After is accepted in
+// both delta-seconds and HTTP-date form. X-RateLimit-Reset and RateLimit-Reset
+// are only honored once the limit is actually hit, since many APIs send them
+// on every response.
+func retryAfter(resp *http.Response, now time.Time) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
 
-		// Ensure the Randomized retryStrategy does not exceed the max duration
-		if RandomizedDuration > max {
-			return max
+	if v := strings.TrimSpace(resp.Header.Get("Retry-After")); v != "" {
+		if secs, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return nonNegative(time.Duration(secs) * time.Second), true
+		}
+		if at, err := http.ParseTime(v); err == nil {
+			return nonNegative(at.Sub(now)), true
 		}
-		return RandomizedDuration
 	}
-}
 
-// RandomizedFullRetryStrategy implements capped exponential retryStrategy with full randomized.
-func RandomizedFullRetryStrategy() RetryStrategy {
-	randSource := newRandSource()
-	return func(min, max time.Duration, attemptNum int, resp *http.Response) time.Duration {
-		duration := attemptNum * 1000000000 << 1
-		randomized := randSource.Intn(duration-attemptNum) + int(min)
-		if randomized > int(max) {
-			return max
+	limited := resp.StatusCode == http.StatusTooManyRequests ||
+		resp.Header.Get("X-RateLimit-Remaining") == "0" ||
+		resp.Header.Get("RateLimit-Remaining") == "0"
+	if !limited {
+		return 0, false
+	}
+	for _, key := range []string{"X-RateLimit-Reset", "RateLimit-Reset"} {
+		v := strings.TrimSpace(resp.Header.Get(key))
+		if v == "" {
+			continue
+		}
+		secs, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			continue
+		}
+		if secs >= unixTimestampThreshold {
+			return nonNegative(time.Unix(int64(secs), 0).Sub(now)), true
 		}
-		return time.Duration(randomized)
+		return nonNegative(time.Duration(secs * float64(time.Second))), true
 	}
+	return 0, false
 }
 
-// ExponentialRandomizedRetryStrategy adds randomized to exponential retryStrategy.
-func ExponentialRandomizedRetryStrategy() RetryStrategy {
-	randSource := newRandSource()
-	return func(min, max time.Duration, attemptNum int, resp *http.Response) time.Duration {
-		base := math.Pow(2, float64(attemptNum)) * float64(min)
-		randomized := randSource.Float64() * (base - float64(min))
-		sleep := time.Duration(base + randomized)
-		if sleep > max {
-			return max
-		}
-		return sleep
+func nonNegative(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
 	}
+	return d
 }
 
-// newRandSource initializes a rand source with a mutex for concurrency safety.
+// newRandSource initializes a time seeded rand source with a mutex for
+// concurrency safety.
 func newRandSource() *rand.Rand {
-	return rand.New(rand.NewSource(time.Now().UnixNano()))
+	return NewRand(time.Now().UnixNano())
 }
@@ -1,6 +1,9 @@
 package httpify
 
 import (
+	"math/rand"
+	"net/http"
+	"strconv"
 	"testing"
 	"time"
 
@@ -48,3 +51,181 @@ func TestExponentialRandomizedRetryStrategy(t *testing.T) {
 	assert.LessOrEqual(t, duration, max)
 	assert.GreaterOrEqual(t, duration, min)
 }
+
+func TestRetryAfterRetryStrategy(t *testing.T) {
+	retryStrategy := RetryAfterRetryStrategy()
+	min := 1 * time.Second
+	max := 30 * time.Second
+
+	newResp := func(code int, header map[string]string) *http.Response {
+		resp := &http.Response{StatusCode: code, Header: http.Header{}}
+		for k, v := range header {
+			resp.Header.Set(k, v)
+		}
+		return resp
+	}
+
+	tests := []struct {
+		name     string
+		resp     *http.Response
+		expected time.Duration
+	}{
+		{"No response", nil, 2 * time.Second},
+		{"No header", newResp(http.StatusServiceUnavailable, nil), 2 * time.Second},
+		{"Delta seconds", newResp(http.StatusTooManyRequests, map[string]string{"Retry-After": "7"}), 7 * time.Second},
+		{"Clamped to max", newResp(http.StatusTooManyRequests, map[string]string{"Retry-After": "3600"}), max},
+		{"Date in the past", newResp(http.StatusServiceUnavailable, map[string]string{"Retry-After": "Wed, 21 Oct 2015 07:28:00 GMT"}), 0},
+		{"RateLimit-Reset delta", newResp(http.StatusTooManyRequests, map[string]string{"RateLimit-Reset": "12"}), 12 * time.Second},
+		{"Reset ignored while under limit", newResp(http.StatusServiceUnavailable, map[string]string{"X-RateLimit-Reset": "12"}), 2 * time.Second},
+		{"Reset honored when remaining is zero", newResp(http.StatusServiceUnavailable, map[string]string{"X-RateLimit-Reset": "12", "X-RateLimit-Remaining": "0"}), 12 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, retryStrategy(min, max, 1, tt.resp))
+		})
+	}
+}
+
+func TestRetryAfterHTTPDate(t *testing.T) {
+	now := time.Now()
+	resp := &http.Response{Header: http.Header{}}
+	resp.Header.Set("Retry-After", now.Add(10*time.Second).UTC().Format(http.TimeFormat))
+
+	wait, ok := retryAfter(resp, now)
+	assert.True(t, ok)
+	assert.InDelta(t, float64(10*time.Second), float64(wait), float64(time.Second))
+
+	resp.Header.Del("Retry-After")
+	resp.StatusCode = http.StatusTooManyRequests
+	resp.Header.Set("X-RateLimit-Reset", strconv.FormatInt(now.Add(20*time.Second).Unix(), 10))
+	wait, ok = retryAfter(resp, now)
+	assert.True(t, ok)
+	assert.InDelta(t, float64(20*time.Second), float64(wait), float64(time.Second))
+}
+
+func TestSeededRetryStrategies(t *testing.T) {
+	constructors := map[string]func(...StrategyOption) RetryStrategy{
+		"linear":      LinearRandomizedRetryStrategy,
+		"full":        RandomizedFullRetryStrategy,
+		"exponential": ExponentialRandomizedRetryStrategy,
+	}
+
+	for name, constructor := range constructors {
+		t.Run(name, func(t *testing.T) {
+			a := constructor(WithSeed(42))
+			b := constructor(WithSeed(42))
+			for attemptNum := 1; attemptNum <= 5; attemptNum++ {
+				assert.Equal(t, a(time.Second, time.Minute, attemptNum, nil), b(time.Second, time.Minute, attemptNum, nil))
+			}
+		})
+	}
+}
+
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time                 { return time.Time(c) }
+func (c fixedClock) NewTimer(d time.Duration) Timer { return SystemClock.NewTimer(d) }
+
+func TestRetryAfterRetryStrategyClock(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	retryStrategy := RetryAfterRetryStrategy(WithClock(fixedClock(now)))
+
+	resp := &http.Response{Header: http.Header{}}
+	resp.Header.Set("Retry-After", now.Add(15*time.Second).Format(http.TimeFormat))
+	assert.Equal(t, 15*time.Second, retryStrategy(time.Second, time.Minute, 0, resp))
+}
+
+// shippedRetryStrategies returns every RetryStrategy of the package, seeded
+// for reproducible runs.
+func shippedRetryStrategies() map[string]RetryStrategy {
+	return map[string]RetryStrategy{
+		"default":     DefaultRetryStrategy(),
+		"linear":      LinearRandomizedRetryStrategy(WithSeed(1)),
+		"full":        RandomizedFullRetryStrategy(WithSeed(2)),
+		"exponential": ExponentialRandomizedRetryStrategy(WithSeed(3)),
+		"equal":       EqualJitterRetryStrategy(WithSeed(4)),
+		"retry-after": RetryAfterRetryStrategy(),
+	}
+}
+
+func TestRetryStrategyBoundsProperty(t *testing.T) {
+	gen := rand.New(rand.NewSource(42))
+
+	for name, retryStrategy := range shippedRetryStrategies() {
+		t.Run(name, func(t *testing.T) {
+			for i := 0; i < 2000; i++ {
+				min := time.Duration(gen.Int63n(int64(5 * time.Second)))
+				max := min + time.Duration(gen.Int63n(int64(2*time.Minute)))
+				attemptNum := gen.Intn(70)
+
+				duration := retryStrategy(min, max, attemptNum, nil)
+				if duration < min || duration > max {
+					t.Fatalf("attempt %d: %s is outside [%s, %s]", attemptNum, duration, min, max)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryStrategyMonotoneProperty(t *testing.T) {
+	const samples = 2000
+	min := 100 * time.Millisecond
+	max := time.Minute
+
+	for name, retryStrategy := range shippedRetryStrategies() {
+		t.Run(name, func(t *testing.T) {
+			prevMean := time.Duration(0)
+			for attemptNum := 0; attemptNum <= 12; attemptNum++ {
+				var total time.Duration
+				for i := 0; i < samples; i++ {
+					total += retryStrategy(min, max, attemptNum, nil)
+				}
+				mean := total / samples
+				// Allow a little noise from sampling.
+				if float64(mean) < float64(prevMean)*0.97 {
+					t.Fatalf("attempt %d: mean %s dropped below previous mean %s", attemptNum, mean, prevMean)
+				}
+				prevMean = mean
+			}
+		})
+	}
+}
+
+func TestBoundedRetryStrategy(t *testing.T) {
+	wild := BoundedRetryStrategy(func(min, max time.Duration, attemptNum int, resp *http.Response) time.Duration {
+		return time.Duration(attemptNum-5) * time.Hour
+	})
+
+	assert.Equal(t, time.Second, wild(time.Second, time.Minute, 0, nil))
+	assert.Equal(t, time.Minute, wild(time.Second, time.Minute, 10, nil))
+	assert.Equal(t, time.Second, wild(time.Second, time.Millisecond, 10, nil), "max below min is raised to min")
+	assert.Equal(t, time.Duration(0), wild(-time.Second, 0, -3, nil))
+}
+
+func TestRandomizedFullRetryStrategyFirstAttempt(t *testing.T) {
+	retryStrategy := RandomizedFullRetryStrategy()
+	assert.NotPanics(t, func() {
+		wait := retryStrategy(time.Second, 10*time.Second, 0, nil)
+		assert.GreaterOrEqual(t, wait, time.Second)
+		assert.LessOrEqual(t, wait, 2*time.Second)
+	})
+}
+
+func TestRetryStrategyFirstAttemptRandomProperty(t *testing.T) {
+	for name, retryStrategy := range shippedRetryStrategies() {
+		// Without a Retry-After header both wait deterministically.
+		if name == "default" || name == "retry-after" {
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			waits := map[time.Duration]bool{}
+			for i := 0; i < 100; i++ {
+				waits[retryStrategy(time.Second, time.Minute, 0, nil)] = true
+			}
+			if len(waits) < 50 {
+				t.Fatalf("only %d distinct waits out of 100 on the first attempt", len(waits))
+			}
+		})
+	}
+}
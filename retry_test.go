@@ -178,3 +178,60 @@ func TestIsTLSCertError(t *testing.T) {
 		})
 	}
 }
+
+func TestStatusRetryPolicy(t *testing.T) {
+	policy := StatusRetryPolicy()
+
+	tests := []struct {
+		name     string
+		resp     *http.Response
+		err      error
+		expected bool
+	}{
+		{
+			name:     "Retryable connection error",
+			err:      &url.Error{Err: &url.Error{}},
+			expected: true,
+		},
+		{
+			name:     "Non-retryable error",
+			err:      &url.Error{Err: x509.UnknownAuthorityError{}},
+			expected: false,
+		},
+		{name: "OK", resp: &http.Response{StatusCode: http.StatusOK}, expected: false},
+		{name: "Not found", resp: &http.Response{StatusCode: http.StatusNotFound}, expected: false},
+		{name: "Request timeout", resp: &http.Response{StatusCode: http.StatusRequestTimeout}, expected: true},
+		{name: "Too many requests", resp: &http.Response{StatusCode: http.StatusTooManyRequests}, expected: true},
+		{name: "Bad gateway", resp: &http.Response{StatusCode: http.StatusBadGateway}, expected: true},
+		{name: "Service unavailable", resp: &http.Response{StatusCode: http.StatusServiceUnavailable}, expected: true},
+		{name: "Not implemented", resp: &http.Response{StatusCode: http.StatusNotImplemented}, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			retry, err := policy(context.Background(), tt.resp, tt.err)
+			if retry != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, retry)
+			}
+			if retry && tt.resp != nil && err == nil {
+				t.Errorf("expected a reason for retrying status %d", tt.resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestStatusRetryPolicyCustomMatchers(t *testing.T) {
+	policy := StatusRetryPolicy(StatusCodes(http.StatusConflict), StatusClass(5))
+
+	for code, expected := range map[int]bool{
+		http.StatusConflict:        true,
+		http.StatusNotImplemented:  true,
+		http.StatusTooManyRequests: false,
+		http.StatusOK:              false,
+	} {
+		retry, _ := policy(context.Background(), &http.Response{StatusCode: code}, nil)
+		if retry != expected {
+			t.Errorf("status %d: expected %v, got %v", code, expected, retry)
+		}
+	}
+}
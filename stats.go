@@ -0,0 +1,276 @@
+package httpify
+
+import (
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultLatencyBuckets are the upper bounds of the latency histogram used by
+// NewStatsCollector when none are given.
+var DefaultLatencyBuckets = []time.Duration{
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// Stats aggregates the calls made through a Client.
+type Stats struct {
+	// Requests is the number of Do calls and Errors the number of those that
+	// returned an error.
+	Requests int64 `json:"requests"`
+	Errors   int64 `json:"errors"`
+	// Attempts and Retries count attempts as in Metrics, and Failures the
+	// attempts that got no response.
+	Attempts int64 `json:"attempts"`
+	Retries  int64 `json:"retries"`
+	Failures int64 `json:"failures"`
+	// ErrorKinds counts the failed attempts by kind.
+	ErrorKinds map[ErrorKind]int64 `json:"error_kinds"`
+	// StatusCodes counts the attempts that got a response by status code.
+	StatusCodes map[int]int64 `json:"status_codes"`
+	// Latency is the distribution of the time attempts took to get the
+	// response headers or an error.
+	Latency Histogram `json:"latency"`
+	// BytesOut is the number of request body bytes sent and BytesIn the
+	// number of response body bytes read, drained bytes included.
+	BytesOut int64 `json:"bytes_out"`
+	BytesIn  int64 `json:"bytes_in"`
+}
+
+// Histogram is a distribution of durations.
+type Histogram struct {
+	// Bounds are the inclusive upper bounds of the buckets.
+	Bounds []time.Duration `json:"bounds"`
+	// Counts has one count per bound, plus a last one for larger values.
+	Counts []int64       `json:"counts"`
+	Count  int64         `json:"count"`
+	Sum    time.Duration `json:"sum"`
+}
+
+func newHistogram(bounds []time.Duration) Histogram {
+	return Histogram{Bounds: bounds, Counts: make([]int64, len(bounds)+1)}
+}
+
+func (h *Histogram) observe(d time.Duration) {
+	i := sort.Search(len(h.Bounds), func(i int) bool { return d <= h.Bounds[i] })
+	h.Counts[i]++
+	h.Count++
+	h.Sum += d
+}
+
+// Mean returns the mean of the observed durations.
+func (h Histogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+func newStats(bounds []time.Duration) *Stats {
+	return &Stats{
+		ErrorKinds:  make(map[ErrorKind]int64),
+		StatusCodes: make(map[int]int64),
+		Latency:     newHistogram(bounds),
+	}
+}
+
+// clone returns a deep copy of s.
+func (s *Stats) clone() Stats {
+	out := *s
+	out.ErrorKinds = make(map[ErrorKind]int64, len(s.ErrorKinds))
+	for kind, n := range s.ErrorKinds {
+		out.ErrorKinds[kind] = n
+	}
+	out.StatusCodes = make(map[int]int64, len(s.StatusCodes))
+	for code, n := range s.StatusCodes {
+		out.StatusCodes[code] = n
+	}
+	out.Latency.Bounds = append([]time.Duration(nil), s.Latency.Bounds...)
+	out.Latency.Counts = append([]int64(nil), s.Latency.Counts...)
+	return out
+}
+
+// DefaultStatsMaxHosts is the number of hosts a StatsCollector breaks its
+// statistics down by when MaxHosts is zero.
+const DefaultStatsMaxHosts = 1000
+
+// StatsOtherHosts is the key of the statistics of the hosts beyond the
+// MaxHosts of a StatsCollector.
+const StatsOtherHosts = "*"
+
+// StatsSnapshot is a copy of the statistics of a StatsCollector.
+type StatsSnapshot struct {
+	Stats
+	// Hosts breaks the statistics down by host, as in URL.Host. Hosts beyond
+	// the MaxHosts of the collector share the StatsOtherHosts entry.
+	Hosts map[string]Stats `json:"hosts"`
+	// Since is when the collector was created or last reset.
+	Since time.Time `json:"since"`
+}
+
+// StatsCollector aggregates the statistics of the calls made by the clients
+// it is set on. It is safe for concurrent use.
+type StatsCollector struct {
+	// MaxHosts caps the number of hosts in the breakdown, which otherwise
+	// grows with every host contacted, e.g. by a crawler. Zero means
+	// DefaultStatsMaxHosts. It must be set before the collector is used.
+	MaxHosts int
+
+	bounds []time.Duration
+
+	mu    sync.Mutex
+	total *Stats
+	hosts map[string]*Stats
+	since time.Time
+}
+
+// NewStatsCollector returns a collector whose latency histograms use the
+// given bucket bounds, DefaultLatencyBuckets when none are given.
+func NewStatsCollector(latencyBuckets ...time.Duration) *StatsCollector {
+	if len(latencyBuckets) == 0 {
+		latencyBuckets = DefaultLatencyBuckets
+	}
+	bounds := append([]time.Duration(nil), latencyBuckets...)
+	sort.Slice(bounds, func(i, j int) bool { return bounds[i] < bounds[j] })
+
+	s := &StatsCollector{bounds: bounds}
+	s.Reset()
+	return s
+}
+
+// Snapshot returns a copy of the statistics collected so far.
+func (s *StatsCollector) Snapshot() StatsSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snapshot := StatsSnapshot{
+		Stats: s.total.clone(),
+		Hosts: make(map[string]Stats, len(s.hosts)),
+		Since: s.since,
+	}
+	for host, stats := range s.hosts {
+		snapshot.Hosts[host] = stats.clone()
+	}
+	return snapshot
+}
+
+// Reset drops the statistics collected so far.
+func (s *StatsCollector) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.total = newStats(s.bounds)
+	s.hosts = make(map[string]*Stats)
+	s.since = time.Now()
+}
+
+// update applies fn to the total and host statistics.
+func (s *StatsCollector) update(host string, fn func(*Stats)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats, ok := s.hosts[host]
+	if !ok {
+		maxHosts := s.MaxHosts
+		if maxHosts <= 0 {
+			maxHosts = DefaultStatsMaxHosts
+		}
+		if len(s.hosts) >= maxHosts {
+			host = StatsOtherHosts
+		}
+		if stats, ok = s.hosts[host]; !ok {
+			stats = newStats(s.bounds)
+			s.hosts[host] = stats
+		}
+	}
+	fn(s.total)
+	fn(stats)
+}
+
+// recordCall records the outcome of a Do call.
+func (s *StatsCollector) recordCall(host string, err error) {
+	s.update(host, func(stats *Stats) {
+		stats.Requests++
+		if err != nil {
+			stats.Errors++
+		}
+	})
+}
+
+// recordAttempt records an attempt whose request body had bytesOut bytes.
+func (s *StatsCollector) recordAttempt(host string, attempt *Attempt, bytesOut int64) {
+	s.update(host, func(stats *Stats) {
+		stats.Attempts++
+		stats.Latency.observe(attempt.Duration)
+		if bytesOut > 0 {
+			stats.BytesOut += bytesOut
+		}
+		if attempt.Err != nil {
+			stats.Failures++
+			stats.ErrorKinds[attempt.ErrorKind]++
+		} else {
+			stats.StatusCodes[attempt.StatusCode]++
+		}
+	})
+}
+
+// recordRetry records a retry.
+func (s *StatsCollector) recordRetry(host string) {
+	s.update(host, func(stats *Stats) {
+		stats.Retries++
+	})
+}
+
+// countBody makes the bytes read from the body of resp count as BytesIn.
+func (s *StatsCollector) countBody(host string, resp *http.Response) {
+	if resp == nil || resp.Body == nil || resp.Body == http.NoBody {
+		return
+	}
+	resp.Body = &countingBody{ReadCloser: resp.Body, flush: func(n int64) {
+		s.update(host, func(stats *Stats) {
+			stats.BytesIn += n
+		})
+	}}
+}
+
+// countingBody counts the bytes read through it and reports them once, when
+// the body is read to the end or closed, so that reads do not contend for
+// the lock of the collector.
+type countingBody struct {
+	io.ReadCloser
+	n     atomic.Int64
+	flush func(n int64)
+	once  sync.Once
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n.Add(int64(n))
+	if err == io.EOF {
+		b.done()
+	}
+	return n, err
+}
+
+func (b *countingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.done()
+	return err
+}
+
+func (b *countingBody) done() {
+	b.once.Do(func() {
+		if n := b.n.Load(); n > 0 {
+			b.flush(n)
+		}
+	})
+}
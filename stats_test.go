@@ -0,0 +1,141 @@
+package httpify
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistogramObserve(t *testing.T) {
+	h := newHistogram([]time.Duration{10 * time.Millisecond, 100 * time.Millisecond})
+	for _, d := range []time.Duration{time.Millisecond, 10 * time.Millisecond, 50 * time.Millisecond, time.Second} {
+		h.observe(d)
+	}
+	assert.Equal(t, []int64{2, 1, 1}, h.Counts)
+	assert.EqualValues(t, 4, h.Count)
+	assert.Equal(t, 1061*time.Millisecond/4, h.Mean())
+	assert.Zero(t, Histogram{}.Mean())
+}
+
+func TestClientStats(t *testing.T) {
+	var flaky int32
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		io.WriteString(w, "hello")
+	}))
+	defer ok.Close()
+	unstable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&flaky, 1)%2 == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer unstable.Close()
+	closed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	closedURL := closed.URL
+	closed.Close()
+
+	stats := NewStatsCollector()
+	client := NewClient(Options{RetryMax: 1, RespReadLimit: 4096, Timeout: time.Second})
+	client.Stats = stats
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := NewRequest(http.MethodPut, ok.URL, strings.NewReader("ping"))
+			resp, err := client.Do(req)
+			if assert.Nil(t, err) {
+				io.ReadAll(resp.Body)
+				resp.Body.Close()
+			}
+		}()
+	}
+	wg.Wait()
+
+	resp, err := client.Get(unstable.URL)
+	if assert.Nil(t, err) {
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+	_, err = client.Get(closedURL)
+	assert.NotNil(t, err)
+
+	snapshot := stats.Snapshot()
+	assert.EqualValues(t, 12, snapshot.Requests)
+	assert.EqualValues(t, 1, snapshot.Errors)
+	assert.EqualValues(t, 14, snapshot.Attempts)
+	assert.EqualValues(t, 2, snapshot.Retries)
+	assert.EqualValues(t, 2, snapshot.Failures)
+	assert.Equal(t, map[ErrorKind]int64{KindConnRefused: 2}, snapshot.ErrorKinds)
+	assert.Equal(t, map[int]int64{http.StatusOK: 11, http.StatusServiceUnavailable: 1}, snapshot.StatusCodes)
+	assert.EqualValues(t, 14, snapshot.Latency.Count)
+	assert.EqualValues(t, 40, snapshot.BytesOut)
+	assert.EqualValues(t, 52, snapshot.BytesIn)
+
+	host := strings.TrimPrefix(ok.URL, "http://")
+	assert.EqualValues(t, 10, snapshot.Hosts[host].Requests)
+	assert.EqualValues(t, 50, snapshot.Hosts[host].BytesIn)
+	unstableHost := strings.TrimPrefix(unstable.URL, "http://")
+	assert.EqualValues(t, 2, snapshot.Hosts[unstableHost].Attempts)
+	assert.EqualValues(t, 1, snapshot.Hosts[unstableHost].Retries)
+	assert.Len(t, snapshot.Hosts, 3)
+
+	data, err := json.Marshal(snapshot)
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), `"conn_refused":2`)
+
+	// Snapshots are copies.
+	snapshot.StatusCodes[http.StatusOK] = 0
+	assert.EqualValues(t, 11, stats.Snapshot().StatusCodes[http.StatusOK])
+
+	stats.Reset()
+	snapshot = stats.Snapshot()
+	assert.Zero(t, snapshot.Requests)
+	assert.Empty(t, snapshot.Hosts)
+	assert.Len(t, snapshot.Latency.Counts, len(DefaultLatencyBuckets)+1)
+}
+
+func TestStatsCollectorMaxHosts(t *testing.T) {
+	stats := NewStatsCollector()
+	stats.MaxHosts = 2
+	for _, host := range []string{"a.example", "b.example", "c.example", "d.example", "a.example"} {
+		stats.recordCall(host, nil)
+	}
+
+	snapshot := stats.Snapshot()
+	assert.EqualValues(t, 5, snapshot.Requests)
+	assert.Len(t, snapshot.Hosts, 3)
+	assert.EqualValues(t, 2, snapshot.Hosts["a.example"].Requests)
+	assert.EqualValues(t, 2, snapshot.Hosts[StatsOtherHosts].Requests)
+}
+
+func TestStatsCountBody(t *testing.T) {
+	stats := NewStatsCollector()
+	resp := &http.Response{Body: io.NopCloser(strings.NewReader("hello world"))}
+	stats.countBody("example.com", resp)
+
+	// Bytes are reported once the body is read to the end or closed.
+	io.ReadFull(resp.Body, make([]byte, 5))
+	assert.Zero(t, stats.Snapshot().BytesIn)
+	io.ReadAll(resp.Body)
+	assert.EqualValues(t, 11, stats.Snapshot().BytesIn)
+	resp.Body.Close()
+	assert.EqualValues(t, 11, stats.Snapshot().BytesIn)
+
+	resp = &http.Response{Body: io.NopCloser(strings.NewReader("hello world"))}
+	stats.countBody("example.com", resp)
+	io.ReadFull(resp.Body, make([]byte, 5))
+	resp.Body.Close()
+	assert.EqualValues(t, 16, stats.Snapshot().Hosts["example.com"].BytesIn)
+}
@@ -0,0 +1,179 @@
+package httpify
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// TLSOptions configures the TLS settings of the transports built by the
+// package. The zero value verifies certificates against the system roots.
+type TLSOptions struct {
+	// InsecureSkipVerify disables certificate verification. It is meant for
+	// scanning hosts whose certificates cannot be trusted and must be opted
+	// into explicitly.
+	InsecureSkipVerify bool `json:"insecure_skip_verify"`
+	// RootCAFiles are PEM bundles trusted instead of the system roots.
+	RootCAFiles []string `json:"root_ca_files"`
+	// CertFile and KeyFile are a PEM client certificate and key for mutual TLS.
+	CertFile string `json:"cert_file"`
+	KeyFile  string `json:"key_file"`
+	// MinVersion and MaxVersion bound the TLS version. Zero keeps the
+	// defaults of crypto/tls.
+	MinVersion TLSVersion `json:"min_version"`
+	MaxVersion TLSVersion `json:"max_version"`
+	// CipherSuites restricts the cipher suites of TLS 1.0 to 1.2.
+	CipherSuites []uint16 `json:"cipher_suites"`
+	// ServerName overrides the name sent for SNI and used for verification.
+	ServerName string `json:"server_name"`
+	// NextProtos is the list of protocols offered through ALPN.
+	NextProtos []string `json:"next_protos"`
+	// Pins maps host names to the SPKI pins accepted for them, see SPKIPin.
+	// The handshake fails with a *PinMismatchError unless a certificate of
+	// the chain has one of the pins, so list backup pins along with the
+	// current one. Pins may carry the "sha256/" prefix. The "*" entry applies
+	// to hosts without pins of their own, including IP addresses, for which
+	// no server name is known.
+	Pins map[string][]string `json:"pins"`
+	// ReloadInterval enables reloading RootCAFiles, CertFile and KeyFile
+	// when they change on disk, which is checked during handshakes at most
+	// once per interval. In files it is written as "reload_interval" with
+	// the syntax of time.ParseDuration. When the config is used outside of
+	// the transports of a Client, hosts given by IP address then need
+	// ServerName to be verified.
+	ReloadInterval time.Duration `json:"-"`
+	// OnReloadError is called when changed files cannot be loaded. The
+	// previous certificate and roots stay in use.
+	OnReloadError func(error) `json:"-"`
+	// Clock tells the time, SystemClock when nil.
+	Clock Clock `json:"-"`
+}
+
+// UnmarshalJSON decodes the options, parsing "reload_interval" as a duration.
+func (o *TLSOptions) UnmarshalJSON(data []byte) error {
+	type plain TLSOptions
+	aux := struct {
+		*plain
+		ReloadInterval string `json:"reload_interval"`
+	}{plain: (*plain)(o)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if aux.ReloadInterval != "" {
+		interval, err := time.ParseDuration(aux.ReloadInterval)
+		if err != nil {
+			return fmt.Errorf("reload_interval: %w", err)
+		}
+		o.ReloadInterval = interval
+	}
+	return nil
+}
+
+// Config builds the *tls.Config described by o, loading the files it refers to.
+func (o TLSOptions) Config() (*tls.Config, error) {
+	cfg, _, err := o.config()
+	return cfg, err
+}
+
+// config is Config, also returning the reloader of the files, if any, to be
+// attached to the transports using the config.
+func (o TLSOptions) config() (*tls.Config, *certReloader, error) {
+	cfg := &tls.Config{
+		Renegotiation:      tls.RenegotiateOnceAsClient,
+		InsecureSkipVerify: o.InsecureSkipVerify,
+		MinVersion:         uint16(o.MinVersion),
+		MaxVersion:         uint16(o.MaxVersion),
+		CipherSuites:       o.CipherSuites,
+		ServerName:         o.ServerName,
+		NextProtos:         o.NextProtos,
+	}
+
+	if len(o.Pins) > 0 {
+		if err := validatePins(o.Pins); err != nil {
+			return nil, nil, err
+		}
+		cfg.VerifyConnection = verifyPins(o.Pins, o.ServerName)
+	}
+
+	if o.ReloadInterval > 0 {
+		reloader, err := newCertReloader(o)
+		if err != nil {
+			return nil, nil, err
+		}
+		reloader.install(cfg)
+		return cfg, reloader, nil
+	}
+
+	if len(o.RootCAFiles) > 0 {
+		pool, err := loadCertPool(o.RootCAFiles)
+		if err != nil {
+			return nil, nil, err
+		}
+		cfg.RootCAs = pool
+	}
+
+	if o.CertFile != "" || o.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil, nil
+}
+
+// loadCertPool reads PEM certificates from files into a new pool.
+func loadCertPool(files []string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	for _, file := range files {
+		pem, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("loading root CAs: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("loading root CAs: no certificate found in %s", file)
+		}
+	}
+	return pool, nil
+}
+
+// TLSVersion is a TLS protocol version such as tls.VersionTLS12. In text
+// form, e.g. in configuration files, it is written as "1.0" to "1.3".
+type TLSVersion uint16
+
+var tlsVersionNames = map[TLSVersion]string{
+	tls.VersionTLS10: "1.0",
+	tls.VersionTLS11: "1.1",
+	tls.VersionTLS12: "1.2",
+	tls.VersionTLS13: "1.3",
+}
+
+// MarshalText encodes the version as "1.0" to "1.3".
+func (v TLSVersion) MarshalText() ([]byte, error) {
+	if v == 0 {
+		return []byte{}, nil
+	}
+	name, ok := tlsVersionNames[v]
+	if !ok {
+		return nil, fmt.Errorf("unknown TLS version %#04x", uint16(v))
+	}
+	return []byte(name), nil
+}
+
+// UnmarshalText decodes a version written as "1.0" to "1.3".
+func (v *TLSVersion) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*v = 0
+		return nil
+	}
+	for version, name := range tlsVersionNames {
+		if name == string(text) {
+			*v = version
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown TLS version %q", text)
+}
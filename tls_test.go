@@ -0,0 +1,199 @@
+package httpify
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeTestCert writes a self-signed certificate for localhost and its key
+// to dir and returns their paths.
+func writeTestCert(t *testing.T, dir, name string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile = filepath.Join(dir, name+".pem")
+	keyFile = filepath.Join(dir, name+"-key.pem")
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDER)
+	return certFile, keyFile
+}
+
+// issueTestCert writes a certificate for localhost issued by the certificate
+// in caFile and caKeyFile, and its key, to dir and returns their paths.
+func issueTestCert(t *testing.T, dir, name, caFile, caKeyFile string) (certFile, keyFile string) {
+	t.Helper()
+	ca, err := tls.LoadX509KeyPair(caFile, caKeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.Leaf, &key.PublicKey, ca.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile = filepath.Join(dir, name+".pem")
+	keyFile = filepath.Join(dir, name+"-key.pem")
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDER)
+	return certFile, keyFile
+}
+
+func writePEM(t *testing.T, filename, blockType string, der []byte) {
+	t.Helper()
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(filename, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newTLSTestServer starts a TLS server presenting the certificate in
+// certFile and keyFile, with its TLS configuration adjusted by configure.
+func newTLSTestServer(t *testing.T, certFile, keyFile string, handler http.Handler, configure ...func(*tls.Config)) *httptest.Server {
+	t.Helper()
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewUnstartedServer(handler)
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	for _, fn := range configure {
+		fn(server.TLS)
+	}
+	// Failed handshakes are expected, keep them out of the test output.
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestTLSOptionsConfig(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCert(t, dir, "client")
+
+	cfg, err := TLSOptions{
+		RootCAFiles: []string{certFile},
+		CertFile:    certFile,
+		KeyFile:     keyFile,
+		MinVersion:  tls.VersionTLS12,
+		MaxVersion:  tls.VersionTLS13,
+		ServerName:  "api.example.com",
+		NextProtos:  []string{"h2", "http/1.1"},
+	}.Config()
+	if assert.Nil(t, err) {
+		assert.False(t, cfg.InsecureSkipVerify)
+		assert.NotNil(t, cfg.RootCAs)
+		assert.Len(t, cfg.Certificates, 1)
+		assert.Equal(t, uint16(tls.VersionTLS12), cfg.MinVersion)
+		assert.Equal(t, "api.example.com", cfg.ServerName)
+		assert.Equal(t, []string{"h2", "http/1.1"}, cfg.NextProtos)
+	}
+
+	_, err = TLSOptions{RootCAFiles: []string{filepath.Join(dir, "missing.pem")}}.Config()
+	assert.NotNil(t, err)
+	_, err = TLSOptions{RootCAFiles: []string{keyFile}}.Config()
+	assert.NotNil(t, err)
+	_, err = TLSOptions{CertFile: certFile}.Config()
+	assert.NotNil(t, err)
+}
+
+func TestTLSVersionText(t *testing.T) {
+	var options TLSOptions
+	err := json.Unmarshal([]byte(`{"min_version": "1.2", "max_version": "1.3"}`), &options)
+	assert.Nil(t, err)
+	assert.Equal(t, TLSVersion(tls.VersionTLS12), options.MinVersion)
+	assert.Equal(t, TLSVersion(tls.VersionTLS13), options.MaxVersion)
+
+	text, err := options.MinVersion.MarshalText()
+	assert.Nil(t, err)
+	assert.Equal(t, "1.2", string(text))
+
+	assert.NotNil(t, json.Unmarshal([]byte(`{"min_version": "2.0"}`), &options))
+}
+
+func TestClientTLSVerification(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCert(t, dir, "server")
+	server := newTLSTestServer(t, certFile, keyFile, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	// Verification is on by default and a certificate error is not retried.
+	client := NewClient(Options{RetryMax: 3, Timeout: 5 * time.Second})
+	req, _ := NewRequest(http.MethodGet, server.URL, nil)
+	_, err := client.Do(req)
+	assert.Equal(t, KindTLSUnknownAuthority, Classify(err))
+	assert.Len(t, req.Metrics.Attempts, 1)
+
+	client = NewClient(Options{Timeout: 5 * time.Second, TLS: TLSOptions{RootCAFiles: []string{certFile}}})
+	resp, err := client.Get(server.URL)
+	if assert.Nil(t, err) {
+		resp.Body.Close()
+	}
+
+	client = NewClient(Options{Timeout: 5 * time.Second, TLS: TLSOptions{InsecureSkipVerify: true}})
+	resp, err = client.Get(server.URL)
+	if assert.Nil(t, err) {
+		resp.Body.Close()
+	}
+
+	client = NewClient(Options{Timeout: 5 * time.Second, TLS: TLSOptions{RootCAFiles: []string{filepath.Join(dir, "missing.pem")}}})
+	_, err = client.Get(server.URL)
+	assert.ErrorContains(t, err, "invalid client options")
+}
@@ -0,0 +1,91 @@
+package httpify
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"time"
+)
+
+// TLSInfo describes a TLS connection and the certificates served on it.
+type TLSInfo struct {
+	// Version is the negotiated TLS version, "1.3" in text form.
+	Version TLSVersion `json:"version"`
+	// CipherSuite is the name of the negotiated cipher suite.
+	CipherSuite string `json:"cipher_suite"`
+	// ALPN is the protocol negotiated through ALPN, if any.
+	ALPN string `json:"alpn,omitempty"`
+	// ServerName is the name sent for SNI, empty for IP addresses.
+	ServerName string `json:"server_name,omitempty"`
+	// Resumed reports whether the session was resumed.
+	Resumed bool `json:"resumed"`
+	// Certificates is the chain presented by the server, leaf first.
+	Certificates []CertificateInfo `json:"certificates"`
+}
+
+// CertificateInfo describes a certificate.
+type CertificateInfo struct {
+	Subject string `json:"subject"`
+	Issuer  string `json:"issuer"`
+	// DNSNames, IPAddresses, EmailAddresses and URIs are the subject
+	// alternative names.
+	DNSNames       []string  `json:"dns_names,omitempty"`
+	IPAddresses    []string  `json:"ip_addresses,omitempty"`
+	EmailAddresses []string  `json:"email_addresses,omitempty"`
+	URIs           []string  `json:"uris,omitempty"`
+	NotBefore      time.Time `json:"not_before"`
+	NotAfter       time.Time `json:"not_after"`
+	// SerialNumber is the serial number in hexadecimal.
+	SerialNumber string `json:"serial_number"`
+	// Fingerprint is the hexadecimal SHA-256 hash of the DER certificate.
+	Fingerprint string `json:"fingerprint_sha256"`
+	// SPKIPin is the pin of the public key, see SPKIPin.
+	SPKIPin string `json:"spki_pin"`
+	IsCA    bool   `json:"is_ca"`
+}
+
+// NewTLSInfo extracts the details of state, typically the TLS field of an
+// *http.Response. It returns nil for a nil state.
+func NewTLSInfo(state *tls.ConnectionState) *TLSInfo {
+	if state == nil {
+		return nil
+	}
+	info := &TLSInfo{
+		Version:      TLSVersion(state.Version),
+		CipherSuite:  tls.CipherSuiteName(state.CipherSuite),
+		ALPN:         state.NegotiatedProtocol,
+		ServerName:   state.ServerName,
+		Resumed:      state.DidResume,
+		Certificates: make([]CertificateInfo, len(state.PeerCertificates)),
+	}
+	for i, cert := range state.PeerCertificates {
+		info.Certificates[i] = newCertificateInfo(cert)
+	}
+	return info
+}
+
+func newCertificateInfo(cert *x509.Certificate) CertificateInfo {
+	fingerprint := sha256.Sum256(cert.Raw)
+	info := CertificateInfo{
+		Subject:        cert.Subject.String(),
+		Issuer:         cert.Issuer.String(),
+		DNSNames:       cert.DNSNames,
+		EmailAddresses: cert.EmailAddresses,
+		NotBefore:      cert.NotBefore,
+		NotAfter:       cert.NotAfter,
+		Fingerprint:    hex.EncodeToString(fingerprint[:]),
+		SPKIPin:        SPKIPin(cert),
+		IsCA:           cert.IsCA,
+	}
+	if cert.SerialNumber != nil {
+		info.SerialNumber = cert.SerialNumber.Text(16)
+	}
+	for _, ip := range cert.IPAddresses {
+		info.IPAddresses = append(info.IPAddresses, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		info.URIs = append(info.URIs, uri.String())
+	}
+	return info
+}
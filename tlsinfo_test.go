@@ -0,0 +1,66 @@
+package httpify
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTLSInfo(t *testing.T) {
+	assert.Nil(t, NewTLSInfo(nil))
+
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCert(t, dir, "server")
+	server := newTLSTestServer(t, certFile, keyFile, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		func(cfg *tls.Config) { cfg.NextProtos = []string{"http/1.1"} })
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := NewClient(Options{Timeout: 5 * time.Second, TLS: TLSOptions{
+		RootCAFiles: []string{certFile},
+		MinVersion:  tls.VersionTLS13,
+		NextProtos:  []string{"http/1.1"},
+	}})
+	req, _ := NewRequest(http.MethodGet, strings.Replace(server.URL, "127.0.0.1", "localhost", 1), nil)
+	resp, err := client.Do(req)
+	if !assert.Nil(t, err) {
+		return
+	}
+	resp.Body.Close()
+
+	info := req.Metrics.Attempts[0].TLS
+	if !assert.NotNil(t, info) {
+		return
+	}
+	assert.Equal(t, NewTLSInfo(resp.TLS), info)
+	assert.Equal(t, TLSVersion(tls.VersionTLS13), info.Version)
+	assert.Equal(t, "http/1.1", info.ALPN)
+	assert.Equal(t, "localhost", info.ServerName)
+	assert.NotEmpty(t, info.CipherSuite)
+	if assert.Len(t, info.Certificates, 1) {
+		leaf := info.Certificates[0]
+		assert.Equal(t, "CN=server", leaf.Subject)
+		assert.Equal(t, "CN=server", leaf.Issuer)
+		assert.Equal(t, []string{"localhost"}, leaf.DNSNames)
+		assert.Equal(t, []string{"127.0.0.1"}, leaf.IPAddresses)
+		assert.Equal(t, cert.Leaf.SerialNumber.Text(16), leaf.SerialNumber)
+		assert.Equal(t, SPKIPin(cert.Leaf), leaf.SPKIPin)
+		assert.Len(t, leaf.Fingerprint, 64)
+		assert.True(t, leaf.NotBefore.Before(leaf.NotAfter))
+	}
+
+	data, err := json.Marshal(info)
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), `"version":"1.3"`)
+	var decoded TLSInfo
+	assert.Nil(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, info.Certificates[0].Fingerprint, decoded.Certificates[0].Fingerprint)
+	assert.Equal(t, info.Version, decoded.Version)
+}
@@ -0,0 +1,334 @@
+package httpify
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"os"
+	"sync"
+	"time"
+)
+
+// certReloader keeps the client certificate and root CAs of TLSOptions in
+// step with their files. Instead of watching them in the background, it
+// checks their modification time and size during handshakes, at most once
+// per ReloadInterval, so it needs no goroutine and no Close. Connections that
+// are already established are kept.
+type certReloader struct {
+	options TLSOptions
+	clock   Clock
+	// next is the VerifyConnection function of the config before install.
+	next func(tls.ConnectionState) error
+
+	mu      sync.Mutex
+	checked time.Time
+	stamps  map[string]fileStamp
+	cert    *tls.Certificate
+	roots   *x509.CertPool
+	// pins are the pins matched by the verified chains of the handshakes,
+	// by chain presented, since the connection state of a response has no
+	// verified chains when r verifies them.
+	pins map[[sha256.Size]byte]string
+}
+
+// maxRecordedPins bounds the pins a certReloader keeps. They are all
+// forgotten when it is reached, the connections made since record theirs.
+const maxRecordedPins = 1024
+
+// fileStamp tells whether a file changed since it was loaded.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// newCertReloader loads the files of options once.
+func newCertReloader(options TLSOptions) (*certReloader, error) {
+	r := &certReloader{options: options, clock: clockOrSystem(options.Clock)}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	r.checked = r.clock.Now()
+	return r, nil
+}
+
+// files returns the files the reloader keeps track of.
+func (r *certReloader) files() []string {
+	files := append([]string(nil), r.options.RootCAFiles...)
+	if r.options.CertFile != "" || r.options.KeyFile != "" {
+		files = append(files, r.options.CertFile, r.options.KeyFile)
+	}
+	return files
+}
+
+// load reads every file. The files are stamped before being read so that a
+// change made while loading is picked up by the next check. On error the
+// previous certificate and roots stay in use.
+func (r *certReloader) load() error {
+	stamps := make(map[string]fileStamp)
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		stamps[file] = fileStamp{info.ModTime(), info.Size()}
+	}
+
+	var roots *x509.CertPool
+	if len(r.options.RootCAFiles) > 0 {
+		pool, err := loadCertPool(r.options.RootCAFiles)
+		if err != nil {
+			return err
+		}
+		roots = pool
+	}
+	var cert *tls.Certificate
+	if r.options.CertFile != "" || r.options.KeyFile != "" {
+		pair, err := tls.LoadX509KeyPair(r.options.CertFile, r.options.KeyFile)
+		if err != nil {
+			return fmt.Errorf("loading client certificate: %w", err)
+		}
+		cert = &pair
+	}
+
+	r.mu.Lock()
+	r.stamps, r.roots, r.cert = stamps, roots, cert
+	r.mu.Unlock()
+	return nil
+}
+
+// changed reports whether a file differs from its stamp. A file that cannot
+// be read counts as changed so that the failure gets reported.
+func (r *certReloader) changed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for file, stamp := range r.stamps {
+		info, err := os.Stat(file)
+		if err != nil || !info.ModTime().Equal(stamp.modTime) || info.Size() != stamp.size {
+			return true
+		}
+	}
+	return false
+}
+
+// refresh reloads the files when the interval elapsed and one of them
+// changed. Failures are passed to OnReloadError.
+func (r *certReloader) refresh() {
+	r.mu.Lock()
+	now := r.clock.Now()
+	due := now.Sub(r.checked) >= r.options.ReloadInterval
+	if due {
+		r.checked = now
+	}
+	r.mu.Unlock()
+
+	if !due || !r.changed() {
+		return
+	}
+	if err := r.load(); err != nil && r.options.OnReloadError != nil {
+		r.options.OnReloadError(err)
+	}
+}
+
+// current returns the certificate and roots in use.
+func (r *certReloader) current() (*tls.Certificate, *x509.CertPool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cert, r.roots
+}
+
+// install makes cfg take its client certificate and roots from r. The roots
+// of a tls.Config cannot change per handshake, so when root CAs are
+// reloaded the chain is verified by VerifyConnection instead, which then
+// runs the verification previously set on cfg, such as pinning.
+func (r *certReloader) install(cfg *tls.Config) {
+	if r.options.CertFile != "" || r.options.KeyFile != "" {
+		cfg.Certificates = nil
+		cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			r.refresh()
+			cert, _ := r.current()
+			return cert, nil
+		}
+	}
+
+	if !r.verifies() {
+		return
+	}
+	cfg.RootCAs = nil
+	cfg.InsecureSkipVerify = true
+	r.next = cfg.VerifyConnection
+	cfg.VerifyConnection = r.verifyConnection("")
+}
+
+// verifies reports whether r verifies the chains presented by servers.
+func (r *certReloader) verifies() bool {
+	return len(r.options.RootCAFiles) > 0 && !r.options.InsecureSkipVerify
+}
+
+// verifyConnection returns a VerifyConnection function verifying the chain
+// for host, the server name of the connection when empty, then running the
+// verification previously set on the config.
+func (r *certReloader) verifyConnection(host string) func(tls.ConnectionState) error {
+	return func(state tls.ConnectionState) error {
+		chains, err := r.verify(state, host)
+		if err != nil {
+			return err
+		}
+		state.VerifiedChains = chains
+		r.recordPin(state, host)
+		if r.next != nil {
+			return r.next(state)
+		}
+		return nil
+	}
+}
+
+// recordPin records the pin of the server matched by the verified chains of
+// state, if any.
+func (r *certReloader) recordPin(state tls.ConnectionState, host string) {
+	if host == "" {
+		host = state.ServerName
+	}
+	if host == "" {
+		host = r.options.ServerName
+	}
+	pin, ok := matchPin(pinsFor(r.options.Pins, host), &state)
+	if !ok {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.pins == nil || len(r.pins) >= maxRecordedPins {
+		r.pins = make(map[[sha256.Size]byte]string)
+	}
+	r.pins[chainKey(state.PeerCertificates)] = pin
+}
+
+// pinnedKey returns the pin recorded for the chain presented in state.
+func (r *certReloader) pinnedKey(state *tls.ConnectionState) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.pins[chainKey(state.PeerCertificates)]
+}
+
+// chainKey identifies a chain of certificates.
+func chainKey(certs []*x509.Certificate) [sha256.Size]byte {
+	h := sha256.New()
+	for _, cert := range certs {
+		h.Write(cert.Raw)
+	}
+	var key [sha256.Size]byte
+	h.Sum(key[:0])
+	return key
+}
+
+// attach makes transport, whose TLSClientConfig was installed by r, verify
+// servers against the host they are dialed for. No server name is sent for
+// IP addresses, so VerifyConnection cannot tell which host an IP address
+// certificate must be valid for; the connections get a config of their own
+// that knows it. The handshakes run in the dialer, bounded by the
+// TLSHandshakeTimeout of transport since net/http only applies it to the
+// connections it dials itself.
+func (r *certReloader) attach(transport *http.Transport) {
+	if r == nil || !r.verifies() {
+		return
+	}
+	cfg := transport.TLSClientConfig
+	dial := transport.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	transport.DialTLSContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		connCfg := cfg.Clone()
+		if connCfg.ServerName == "" {
+			connCfg.ServerName = host
+		}
+		connCfg.VerifyConnection = r.verifyConnection(connCfg.ServerName)
+		tlsConn := tls.Client(conn, connCfg)
+		if err := handshake(ctx, tlsConn, transport.TLSHandshakeTimeout); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return tlsConn, nil
+	}
+}
+
+// handshake runs the handshake of conn within timeout, if positive, and
+// traces it like net/http does.
+func handshake(ctx context.Context, conn *tls.Conn, timeout time.Duration) error {
+	trace := httptrace.ContextClientTrace(ctx)
+	if trace != nil && trace.TLSHandshakeStart != nil {
+		trace.TLSHandshakeStart()
+	}
+	hsCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		hsCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	err := conn.HandshakeContext(hsCtx)
+	if err != nil && ctx.Err() == nil && hsCtx.Err() != nil {
+		err = tlsHandshakeTimeoutError{}
+	}
+	if trace != nil && trace.TLSHandshakeDone != nil {
+		trace.TLSHandshakeDone(conn.ConnectionState(), err)
+	}
+	return err
+}
+
+// tlsHandshakeTimeoutError is the error net/http returns when
+// TLSHandshakeTimeout elapses, which it does not export.
+type tlsHandshakeTimeoutError struct{}
+
+func (tlsHandshakeTimeoutError) Timeout() bool   { return true }
+func (tlsHandshakeTimeoutError) Temporary() bool { return true }
+func (tlsHandshakeTimeoutError) Error() string   { return "net/http: TLS handshake timeout" }
+
+// verify verifies the chain presented by the server for host the way
+// crypto/tls does, against the current roots.
+func (r *certReloader) verify(state tls.ConnectionState, host string) ([][]*x509.Certificate, error) {
+	r.refresh()
+	_, roots := r.current()
+
+	if len(state.PeerCertificates) == 0 {
+		return nil, errors.New("httpify: server presented no certificate")
+	}
+	if host == "" {
+		host = state.ServerName
+	}
+	if host == "" {
+		host = r.options.ServerName
+	}
+	if host == "" {
+		// No server name is sent for IP addresses, so the host is unknown
+		// unless the connection was dialed by a transport r is attached to.
+		return nil, errors.New("httpify: verifying a host without server name needs TLSOptions.ServerName when root CAs are reloaded outside of a Client")
+	}
+
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		DNSName:       host,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range state.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	chains, err := state.PeerCertificates[0].Verify(opts)
+	if err != nil {
+		return nil, &tls.CertificateVerificationError{UnverifiedCertificates: state.PeerCertificates, Err: err}
+	}
+	return chains, nil
+}
@@ -0,0 +1,218 @@
+package httpify
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// steppedClock is a Clock whose time only moves when advanced.
+type steppedClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *steppedClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *steppedClock) NewTimer(d time.Duration) Timer { return SystemClock.NewTimer(d) }
+
+func (c *steppedClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+// replaceFile overwrites dst with the content of src and moves its
+// modification time forward, as a rotation agent would.
+func replaceFile(t *testing.T, src, dst string) {
+	t.Helper()
+	data, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dst, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(dst, later, later); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTLSReloadClientCertificate(t *testing.T) {
+	dir, rotated := t.TempDir(), t.TempDir()
+	serverCert, serverKey := writeTestCert(t, dir, "server")
+	certFile, keyFile := writeTestCert(t, dir, "client")
+	newCertFile, newKeyFile := writeTestCert(t, rotated, "client")
+
+	var mu sync.Mutex
+	var seen []string
+	server := newTLSTestServer(t, serverCert, serverKey, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen = append(seen, SPKIPin(r.TLS.PeerCertificates[0]))
+		mu.Unlock()
+	}), func(cfg *tls.Config) { cfg.ClientAuth = tls.RequireAnyClientCert })
+	url := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+
+	clock := &steppedClock{now: time.Now()}
+	client := NewClient(Options{Timeout: 5 * time.Second, TLS: TLSOptions{
+		RootCAFiles:    []string{serverCert},
+		CertFile:       certFile,
+		KeyFile:        keyFile,
+		ReloadInterval: time.Minute,
+		Clock:          clock,
+	}})
+	oldPair, _ := tls.LoadX509KeyPair(certFile, keyFile)
+	newPair, _ := tls.LoadX509KeyPair(newCertFile, newKeyFile)
+	get := func() {
+		resp, err := client.Get(url)
+		if assert.Nil(t, err) {
+			resp.Body.Close()
+		}
+	}
+
+	get()
+	replaceFile(t, newCertFile, certFile)
+	replaceFile(t, newKeyFile, keyFile)
+	// The files are not checked again before the interval elapsed.
+	get()
+	clock.Advance(time.Minute)
+	get()
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{SPKIPin(oldPair.Leaf), SPKIPin(oldPair.Leaf), SPKIPin(newPair.Leaf)}, seen)
+}
+
+func TestTLSReloadRootCAs(t *testing.T) {
+	dir, other := t.TempDir(), t.TempDir()
+	oldCert, oldKey := writeTestCert(t, dir, "old")
+	newCert, newKey := writeTestCert(t, other, "new")
+	oldServer := newTLSTestServer(t, oldCert, oldKey, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	newServer := newTLSTestServer(t, newCert, newKey, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	localhost := func(url string) string { return strings.Replace(url, "127.0.0.1", "localhost", 1) }
+
+	caFile := filepath.Join(dir, "ca.pem")
+	replaceFile(t, oldCert, caFile)
+
+	var reloadErrs []error
+	clock := &steppedClock{now: time.Now()}
+	client := NewClient(Options{Timeout: 5 * time.Second, TLS: TLSOptions{
+		RootCAFiles:    []string{caFile},
+		ReloadInterval: time.Second,
+		OnReloadError:  func(err error) { reloadErrs = append(reloadErrs, err) },
+		Clock:          clock,
+	}})
+	get := func(url string) error {
+		resp, err := client.Get(url)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	assert.Nil(t, get(localhost(oldServer.URL)))
+	assert.Equal(t, KindTLSUnknownAuthority, Classify(get(localhost(newServer.URL))))
+	// Hosts given by IP address are verified against their IP SANs.
+	assert.Nil(t, get(oldServer.URL))
+
+	// A broken bundle is reported and the previous roots stay in use.
+	if err := os.WriteFile(caFile, []byte("garbage"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Second)
+	assert.Nil(t, get(localhost(oldServer.URL)))
+	assert.Len(t, reloadErrs, 1)
+
+	replaceFile(t, newCert, caFile)
+	clock.Advance(time.Second)
+	assert.Nil(t, get(localhost(newServer.URL)))
+	assert.Equal(t, KindTLSUnknownAuthority, Classify(get(localhost(oldServer.URL))))
+	assert.Len(t, reloadErrs, 1)
+}
+
+func TestTLSReloadRootCAsIPHost(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	writePEM(t, caFile, "CERTIFICATE", server.Certificate().Raw)
+
+	tests := []struct {
+		name       string
+		serverName string
+		kind       ErrorKind
+	}{
+		{"IP SAN", "", KindNone},
+		{"Server name", "example.com", KindNone},
+		{"Wrong server name", "other.example", KindTLSHostnameMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewClient(Options{Timeout: 5 * time.Second, TLS: TLSOptions{
+				RootCAFiles:    []string{caFile},
+				ServerName:     tt.serverName,
+				ReloadInterval: time.Second,
+			}})
+			resp, err := client.Get(server.URL)
+			if err == nil {
+				resp.Body.Close()
+			}
+			assert.Equal(t, tt.kind, Classify(err), err)
+		})
+	}
+}
+
+func TestTLSReloadHandshakeTimeout(t *testing.T) {
+	certFile, _ := writeTestCert(t, t.TempDir(), "ca")
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	// The server accepts connections and never answers the handshake.
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	client := NewClient(Options{Timeout: 5 * time.Second, TLS: TLSOptions{
+		RootCAFiles:    []string{certFile},
+		ReloadInterval: time.Second,
+	}})
+	client.HTTPClient.Transport.(*http.Transport).TLSHandshakeTimeout = 50 * time.Millisecond
+
+	start := time.Now()
+	_, err = client.Get("https://" + listener.Addr().String())
+	assert.Equal(t, KindTLSHandshakeTimeout, Classify(err), err)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestTLSOptionsReloadIntervalJSON(t *testing.T) {
+	var options TLSOptions
+	err := json.Unmarshal([]byte(`{"cert_file": "client.pem", "reload_interval": "5m"}`), &options)
+	assert.Nil(t, err)
+	assert.Equal(t, "client.pem", options.CertFile)
+	assert.Equal(t, 5*time.Minute, options.ReloadInterval)
+
+	assert.NotNil(t, json.Unmarshal([]byte(`{"reload_interval": "soon"}`), &options))
+}
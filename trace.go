@@ -0,0 +1,304 @@
+package httpify
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// ConnTrace records the phases of a single attempt. The time of a phase that
+// did not happen is zero, e.g. DNS and connect for a reused connection.
+type ConnTrace struct {
+	Method string
+	// URL is redacted by the Redactor of the client.
+	URL string
+	// Attempt is the number of the attempt within the Do call, from 1.
+	Attempt int
+	Start   time.Time
+
+	DNSStart          time.Time
+	DNSDone           time.Time
+	ConnectStart      time.Time
+	ConnectDone       time.Time
+	TLSHandshakeStart time.Time
+	TLSHandshakeDone  time.Time
+	GotConn           time.Time
+	WroteHeaders      time.Time
+	WroteRequest      time.Time
+	FirstByte         time.Time
+	// BodyDone is when the response body was read to the end or closed.
+	BodyDone time.Time
+
+	// Reused reports whether the connection served an earlier request, and
+	// WasIdle and IdleTime how long it waited in the pool.
+	Reused     bool
+	WasIdle    bool
+	IdleTime   time.Duration
+	RemoteAddr string
+
+	StatusCode int
+	// Err is the error of the attempt, with its message redacted.
+	Err error
+}
+
+// DNS returns the duration of the DNS lookup.
+func (t ConnTrace) DNS() time.Duration { return between(t.DNSStart, t.DNSDone) }
+
+// Connect returns the duration of the TCP connect.
+func (t ConnTrace) Connect() time.Duration { return between(t.ConnectStart, t.ConnectDone) }
+
+// TLSHandshake returns the duration of the TLS handshake.
+func (t ConnTrace) TLSHandshake() time.Duration {
+	return between(t.TLSHandshakeStart, t.TLSHandshakeDone)
+}
+
+// TimeToFirstByte returns the time from the start of the attempt to the
+// first byte of the response.
+func (t ConnTrace) TimeToFirstByte() time.Duration { return between(t.Start, t.FirstByte) }
+
+// Total returns the time from the start of the attempt to the end of the
+// body, or of the latest phase for attempts without a response.
+func (t ConnTrace) Total() time.Duration {
+	end := t.Start
+	for _, phase := range []time.Time{t.DNSDone, t.ConnectDone, t.TLSHandshakeDone, t.GotConn, t.WroteRequest, t.FirstByte, t.BodyDone} {
+		if phase.After(end) {
+			end = phase
+		}
+	}
+	return end.Sub(t.Start)
+}
+
+// between returns the time from start to end, zero when either is missing.
+func between(start, end time.Time) time.Duration {
+	if start.IsZero() || end.IsZero() {
+		return 0
+	}
+	return end.Sub(start)
+}
+
+// TraceSink receives the trace of every attempt once it is complete: when
+// the attempt failed, or when its response body is closed.
+type TraceSink interface {
+	Trace(ConnTrace)
+}
+
+// TraceFunc is a TraceSink calling a function.
+type TraceFunc func(ConnTrace)
+
+// Trace calls f.
+func (f TraceFunc) Trace(t ConnTrace) {
+	f(t)
+}
+
+// SlogTraceSink returns a TraceSink logging one record per attempt to logger.
+func SlogTraceSink(logger *slog.Logger, level slog.Level) TraceSink {
+	return TraceFunc(func(t ConnTrace) {
+		attrs := []slog.Attr{
+			slog.String(LogKeyMethod, t.Method),
+			slog.String(LogKeyURL, t.URL),
+			slog.Int(LogKeyAttempt, t.Attempt),
+			slog.String(LogKeyRemoteAddr, t.RemoteAddr),
+			slog.Bool("reused", t.Reused),
+			slog.Duration("dns", t.DNS()),
+			slog.Duration("connect", t.Connect()),
+			slog.Duration("tls_handshake", t.TLSHandshake()),
+			slog.Duration("ttfb", t.TimeToFirstByte()),
+			slog.Duration(LogKeyDuration, t.Total()),
+		}
+		if t.StatusCode != 0 {
+			attrs = append(attrs, slog.Int(LogKeyStatus, t.StatusCode))
+		}
+		attrs = append(attrs, errorAttrs(t.Err)...)
+		logger.LogAttrs(context.Background(), level, "httpify: attempt trace", attrs...)
+	})
+}
+
+// TraceCollector is a TraceSink keeping every trace in memory, e.g. for
+// tests. It is safe for concurrent use.
+type TraceCollector struct {
+	mu     sync.Mutex
+	traces []ConnTrace
+}
+
+// Trace stores t.
+func (c *TraceCollector) Trace(t ConnTrace) {
+	c.mu.Lock()
+	c.traces = append(c.traces, t)
+	c.mu.Unlock()
+}
+
+// Traces returns the traces received so far.
+func (c *TraceCollector) Traces() []ConnTrace {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]ConnTrace(nil), c.traces...)
+}
+
+// Reset drops the traces received so far.
+func (c *TraceCollector) Reset() {
+	c.mu.Lock()
+	c.traces = nil
+	c.mu.Unlock()
+}
+
+// traceSink returns the sink of req, or else the one of the client.
+func (c *Client) traceSink(req *Request) TraceSink {
+	if req.TraceSink != nil {
+		return req.TraceSink
+	}
+	return c.TraceSink
+}
+
+// connTracer fills a ConnTrace from httptrace hooks, which may run on other
+// goroutines, and hands it to the sink once.
+type connTracer struct {
+	sink     TraceSink
+	clock    Clock
+	redactor *Redactor
+
+	mu        sync.Mutex
+	trace     ConnTrace
+	delivered bool
+}
+
+// newConnTracer starts the trace of an attempt, whose URL and error are
+// redacted by redactor.
+func newConnTracer(sink TraceSink, clock Clock, redactor *Redactor, req *Request, attempt int) *connTracer {
+	return &connTracer{
+		sink:     sink,
+		clock:    clock,
+		redactor: redactor,
+		trace: ConnTrace{
+			Method:  req.Method,
+			URL:     redactor.URL(req.URL),
+			Attempt: attempt,
+			Start:   clock.Now(),
+		},
+	}
+}
+
+// set records a phase at the current time.
+func (t *connTracer) set(fn func(tr *ConnTrace, now time.Time)) {
+	now := t.clock.Now()
+	t.mu.Lock()
+	fn(&t.trace, now)
+	t.mu.Unlock()
+}
+
+// withContext returns a context whose httptrace hooks record into t.
+func (t *connTracer) withContext(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.set(func(tr *ConnTrace, now time.Time) { tr.DNSStart = now })
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.set(func(tr *ConnTrace, now time.Time) { tr.DNSDone = now })
+		},
+		ConnectStart: func(string, string) {
+			t.set(func(tr *ConnTrace, now time.Time) {
+				if tr.ConnectStart.IsZero() {
+					tr.ConnectStart = now
+				}
+			})
+		},
+		ConnectDone: func(string, string, error) {
+			t.set(func(tr *ConnTrace, now time.Time) { tr.ConnectDone = now })
+		},
+		TLSHandshakeStart: func() {
+			// Handshakes run by a TLS dialer are traced again, once done, by
+			// net/http.
+			t.set(func(tr *ConnTrace, now time.Time) {
+				if tr.TLSHandshakeStart.IsZero() {
+					tr.TLSHandshakeStart = now
+				}
+			})
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.set(func(tr *ConnTrace, now time.Time) { tr.TLSHandshakeDone = now })
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.set(func(tr *ConnTrace, now time.Time) {
+				tr.GotConn = now
+				tr.Reused, tr.WasIdle, tr.IdleTime = info.Reused, info.WasIdle, info.IdleTime
+				if info.Conn != nil {
+					tr.RemoteAddr = info.Conn.RemoteAddr().String()
+				}
+			})
+		},
+		WroteHeaders: func() {
+			t.set(func(tr *ConnTrace, now time.Time) { tr.WroteHeaders = now })
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			t.set(func(tr *ConnTrace, now time.Time) { tr.WroteRequest = now })
+		},
+		GotFirstResponseByte: func() {
+			t.set(func(tr *ConnTrace, now time.Time) { tr.FirstByte = now })
+		},
+	})
+}
+
+// finish records the outcome of the attempt. Without a response the trace is
+// delivered right away, otherwise once the body of resp is closed.
+func (t *connTracer) finish(resp *http.Response, err error) {
+	t.mu.Lock()
+	t.trace.Err = t.redactor.Error(err)
+	if resp != nil {
+		t.trace.StatusCode = resp.StatusCode
+	}
+	t.mu.Unlock()
+
+	if resp == nil || resp.Body == nil {
+		t.deliver()
+		return
+	}
+	resp.Body = &traceBody{ReadCloser: resp.Body, tracer: t}
+}
+
+// bodyDone records the end of the body, the first time only.
+func (t *connTracer) bodyDone() {
+	t.set(func(tr *ConnTrace, now time.Time) {
+		if tr.BodyDone.IsZero() {
+			tr.BodyDone = now
+		}
+	})
+}
+
+// deliver hands the trace to the sink, once.
+func (t *connTracer) deliver() {
+	t.mu.Lock()
+	if t.delivered {
+		t.mu.Unlock()
+		return
+	}
+	t.delivered = true
+	trace := t.trace
+	t.mu.Unlock()
+	t.sink.Trace(trace)
+}
+
+// traceBody completes the trace of an attempt when its body is read to the
+// end or closed.
+type traceBody struct {
+	io.ReadCloser
+	tracer *connTracer
+}
+
+func (b *traceBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.tracer.bodyDone()
+	}
+	return n, err
+}
+
+func (b *traceBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.tracer.bodyDone()
+	b.tracer.deliver()
+	return err
+}
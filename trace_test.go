@@ -0,0 +1,125 @@
+package httpify
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoTracesAttempts(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, "done")
+	}))
+	defer server.Close()
+
+	collector := &TraceCollector{}
+	client := NewWithHTTPClient(&http.Client{Transport: PooledTransport()}, Options{RetryMax: 2, RespReadLimit: 4096})
+	client.TraceSink = collector
+
+	req, _ := NewRequest(http.MethodGet, server.URL+"/path", nil)
+	resp, err := client.Do(req)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	// The trace of the last attempt is only complete once the body is closed.
+	traces := collector.Traces()
+	if assert.Len(t, traces, 1) {
+		first := traces[0]
+		assert.Equal(t, 1, first.Attempt)
+		assert.Equal(t, http.MethodGet, first.Method)
+		assert.Equal(t, server.URL+"/path", first.URL)
+		assert.Equal(t, http.StatusServiceUnavailable, first.StatusCode)
+		assert.False(t, first.Reused)
+		assert.False(t, first.ConnectDone.IsZero())
+		assert.False(t, first.FirstByte.IsZero())
+		assert.False(t, first.BodyDone.IsZero())
+		assert.Equal(t, server.Listener.Addr().String(), first.RemoteAddr)
+	}
+
+	io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body.Close()
+
+	traces = collector.Traces()
+	if assert.Len(t, traces, 2) {
+		second := traces[1]
+		assert.Equal(t, 2, second.Attempt)
+		assert.Equal(t, http.StatusOK, second.StatusCode)
+		assert.True(t, second.Reused)
+		assert.True(t, second.ConnectStart.IsZero())
+		assert.False(t, second.WroteRequest.IsZero())
+		assert.False(t, second.BodyDone.Before(second.FirstByte))
+		assert.True(t, second.Total() >= second.TimeToFirstByte())
+	}
+
+	collector.Reset()
+	assert.Empty(t, collector.Traces())
+}
+
+func TestDoTracesFailedAttempts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := server.URL
+	server.Close()
+
+	clientSink, requestSink := &TraceCollector{}, &TraceCollector{}
+	client := NewClient(Options{RetryMax: 1, RetryWaitMin: time.Millisecond, RetryWaitMax: time.Millisecond, Timeout: time.Second})
+	client.TraceSink = clientSink
+
+	req, _ := NewRequest(http.MethodGet, url, nil)
+	req.TraceSink = requestSink
+	_, err := client.Do(req)
+	assert.NotNil(t, err)
+
+	assert.Empty(t, clientSink.Traces())
+	traces := requestSink.Traces()
+	if assert.Len(t, traces, 2) {
+		assert.Equal(t, KindConnRefused, Classify(traces[1].Err))
+		assert.Zero(t, traces[1].StatusCode)
+		assert.True(t, traces[1].FirstByte.IsZero())
+	}
+}
+
+func TestSlogTraceSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := SlogTraceSink(slog.New(slog.NewTextHandler(&buf, nil)), slog.LevelInfo)
+
+	start := time.Now()
+	sink.Trace(ConnTrace{
+		Method:       http.MethodGet,
+		URL:          "https://example.com",
+		Attempt:      1,
+		Start:        start,
+		DNSStart:     start,
+		DNSDone:      start.Add(5 * time.Millisecond),
+		FirstByte:    start.Add(20 * time.Millisecond),
+		BodyDone:     start.Add(30 * time.Millisecond),
+		StatusCode:   http.StatusOK,
+		RemoteAddr:   "192.0.2.1:443",
+		ConnectStart: start.Add(5 * time.Millisecond),
+	})
+
+	line := buf.String()
+	for _, want := range []string{"level=INFO", "method=GET", "url=https://example.com", "attempt=1", "status=200", "dns=5ms", "ttfb=20ms", "duration=30ms", "connect=0s"} {
+		assert.Contains(t, line, want)
+	}
+}
+
+func TestFromRequestWithTrace(t *testing.T) {
+	httpReq, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	req, err := FromRequestWithTrace(httpReq)
+	assert.Nil(t, err)
+	assert.NotNil(t, req.TraceSink)
+}